@@ -0,0 +1,230 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/negroni"
+)
+
+// AccessLogFormat is the format of the access log lines
+type AccessLogFormat string
+
+const (
+	// AccessLogStructured logs each request through the server logger with its fields as attributes
+	AccessLogStructured AccessLogFormat = "structured"
+	// AccessLogCommon writes the Apache common log format
+	AccessLogCommon AccessLogFormat = "common"
+	// AccessLogCombined writes the Apache combined log format with the referer and user agent
+	AccessLogCombined AccessLogFormat = "combined"
+	// AccessLogJSON writes a JSON object per line
+	AccessLogJSON AccessLogFormat = "json"
+	// AccessLogLogfmt writes a line of key=value pairs
+	AccessLogLogfmt AccessLogFormat = "logfmt"
+)
+
+// apacheTimeFormat is the time format of the Apache log formats
+const apacheTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogKey is the context key of the access log entry of the request
+type accessLogKey struct{}
+
+// accessLogEntry is the part of the access log filled in while the request is routed
+type accessLogEntry struct {
+	route string
+}
+
+// accessLogField is a single field of an access log line
+type accessLogField struct {
+	key   string
+	value interface{}
+}
+
+// accessLogger is the middleware writing a line for each request served
+type accessLogger struct {
+	server     *Server
+	format     AccessLogFormat
+	out        io.Writer
+	sampleRate float64
+	exclude    []string
+
+	mu sync.Mutex
+}
+
+// ServeHTTP logs the request once it has been served. Requests to the excluded paths are
+// not logged and only the sample rate of the requests without a server error are logged.
+func (l *accessLogger) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if l.excluded(r.URL.Path) {
+		next(w, r)
+		return
+	}
+
+	start := time.Now()
+	entry := &accessLogEntry{}
+	body := &countingReader{ReadCloser: r.Body}
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	req := r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
+	if r.Body != nil && r.Body != http.NoBody {
+		req.Body = body
+	}
+
+	next(rw, req)
+
+	status := rw.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < http.StatusInternalServerError && l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return
+	}
+
+	l.log(r, entry.route, status, time.Since(start), body.n, rw.Size(), start)
+}
+
+// excluded tells if the path is not logged, a path ending in * excludes the paths it prefixes
+func (l *accessLogger) excluded(path string) bool {
+	for _, exclude := range l.exclude {
+		if prefix := strings.TrimSuffix(exclude, "*"); prefix != exclude {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == exclude {
+			return true
+		}
+	}
+
+	return false
+}
+
+// log writes the access log line of the request in the format of the logger
+func (l *accessLogger) log(r *http.Request, route string, status int, latency time.Duration, bytesIn int64, bytesOut int, start time.Time) {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+
+	fields := []accessLogField{
+		{"time", start.Format(time.RFC3339Nano)},
+		{"method", r.Method},
+		{"path", r.URL.RequestURI()},
+		{"route", route},
+		{"proto", r.Proto},
+		{"status", status},
+		{"latencyMs", float64(latency.Microseconds()) / 1000},
+		{"bytesIn", bytesIn},
+		{"bytesOut", bytesOut},
+		{"clientIp", clientIP},
+		{"userAgent", r.UserAgent()},
+		{"referer", r.Referer()},
+		{"requestId", RequestIDFromContext(r.Context())},
+	}
+	if sc := SpanContextFromContext(r.Context()); sc.IsValid() {
+		fields = append(fields, accessLogField{"traceId", sc.TraceID.String()})
+	}
+
+	if l.format == AccessLogStructured || l.format == "" {
+		attrs := make([]interface{}, 0, len(fields)*2)
+		for _, field := range fields[1:] {
+			attrs = append(attrs, field.key, field.value)
+		}
+		l.server.Logger().Info("Request", attrs...)
+		return
+	}
+
+	var line bytes.Buffer
+	switch l.format {
+	case AccessLogCommon, AccessLogCombined:
+		fmt.Fprintf(&line, "%s - - [%s] %q %d %s", clientIP, start.Format(apacheTimeFormat),
+			r.Method+" "+r.URL.RequestURI()+" "+r.Proto, status, apacheBytes(bytesOut))
+		if l.format == AccessLogCombined {
+			fmt.Fprintf(&line, " %q %q", apacheString(r.Referer()), apacheString(r.UserAgent()))
+		}
+	case AccessLogJSON:
+		line.WriteByte('{')
+		for i, field := range fields {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			key, _ := json.Marshal(field.key)
+			value, _ := json.Marshal(field.value)
+			line.Write(key)
+			line.WriteByte(':')
+			line.Write(value)
+		}
+		line.WriteByte('}')
+	case AccessLogLogfmt:
+		for i, field := range fields {
+			if i > 0 {
+				line.WriteByte(' ')
+			}
+			line.WriteString(field.key + "=" + logfmtValue(field.value))
+		}
+	}
+	line.WriteByte('\n')
+
+	out := l.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out.Write(line.Bytes())
+}
+
+// apacheBytes returns the size in the Apache format which is "-" when nothing was written
+func apacheBytes(n int) string {
+	if n == 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(n)
+}
+
+// apacheString returns the value in the Apache format which is "-" when empty
+func apacheString(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// logfmtValue formats the value quoting it when it is empty or has spaces, quotes or equal signs
+func logfmtValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read from the body adding to the count
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,217 @@
+package srv_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_AccessLog(t *testing.T) {
+	t.Run("Structured", testServer_AccessLog_Structured)
+	t.Run("Common", testServer_AccessLog_Common)
+	t.Run("Combined", testServer_AccessLog_Combined)
+	t.Run("JSON", testServer_AccessLog_JSON)
+	t.Run("Logfmt", testServer_AccessLog_Logfmt)
+	t.Run("Exclude", testServer_AccessLog_Exclude)
+	t.Run("SampleRate", testServer_AccessLog_SampleRate)
+}
+
+// startAccessLogged starts a server with an echo route at /echo/:name writing the access log in
+// the format given to the buffer, the structured format is logged as JSON through the server logger
+func startAccessLogged(logs *logBuffer, format srv.AccessLogFormat, opts ...srv.Option) *srv.Server {
+	logger := slog.New(slog.NewJSONHandler(ioutil.Discard, nil))
+	if format == srv.AccessLogStructured {
+		logger = slog.New(slog.NewJSONHandler(logs, nil))
+	}
+
+	opts = append([]srv.Option{
+		srv.OptionLogger(logger),
+		srv.OptionAccessLogFormat(format),
+		srv.OptionAccessLogOutput(logs),
+	}, opts...)
+
+	s := srv.New(opts...)
+	s.POST("/echo/:name", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+	s.GET("/fail", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s.Start("127.0.0.1:0")
+
+	return s
+}
+
+// stopAccessLogged shuts the server down once the idle client connections are closed so a
+// connection dialed but not used by the client does not hold up the shutdown
+func stopAccessLogged(s *srv.Server) {
+	http.DefaultClient.CloseIdleConnections()
+	s.Shutdown()
+}
+
+// postEcho sends the body to the echo route with a request id, user agent and referer
+func postEcho(s *srv.Server, body string) error {
+	req, _ := http.NewRequest("POST", "http://"+s.Addr().String()+"/echo/test?q=1", strings.NewReader(body))
+	req.Header.Set(srv.RequestIDHeader, "access-1")
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	req.Header.Set("Referer", "http://example.com/")
+
+	res, err := http.DefaultClient.Do(req)
+	if err == nil {
+		res.Body.Close()
+	}
+
+	return err
+}
+
+func testServer_AccessLog_Structured(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogStructured)
+	defer stopAccessLogged(s)
+
+	// Act
+	err := postEcho(s, "hello")
+	waitFor(func() bool { return len(logs.entries("Request")) > 0 })
+
+	// Assert
+	assert.NoError(err)
+	if entries := logs.entries("Request"); assert.Len(entries, 1) {
+		assert.Equal("POST", entries[0]["method"])
+		assert.Equal("/echo/test?q=1", entries[0]["path"])
+		assert.Equal("/echo/:name", entries[0]["route"])
+		assert.Equal(float64(http.StatusOK), entries[0]["status"])
+		assert.Equal(float64(5), entries[0]["bytesIn"])
+		assert.Equal(float64(5), entries[0]["bytesOut"])
+		assert.Equal("127.0.0.1", entries[0]["clientIp"])
+		assert.Equal("test-agent/1.0", entries[0]["userAgent"])
+		assert.Equal("access-1", entries[0]["requestId"])
+		assert.Contains(entries[0], "latencyMs")
+	}
+}
+
+func testServer_AccessLog_Common(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogCommon)
+	defer stopAccessLogged(s)
+
+	// Act
+	err := postEcho(s, "hello")
+	waitFor(func() bool { return logs.contains("POST") })
+
+	// Assert
+	assert.NoError(err)
+	assert.Regexp(regexp.MustCompile(`^127\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /echo/test\?q=1 HTTP/1\.1" 200 5\n$`), logs.String())
+}
+
+func testServer_AccessLog_Combined(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogCombined)
+	defer stopAccessLogged(s)
+
+	// Act
+	err := postEcho(s, "hello")
+	waitFor(func() bool { return logs.contains("POST") })
+
+	// Assert
+	assert.NoError(err)
+	assert.True(strings.HasSuffix(logs.String(), `"POST /echo/test?q=1 HTTP/1.1" 200 5 "http://example.com/" "test-agent/1.0"`+"\n"))
+}
+
+func testServer_AccessLog_JSON(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogJSON)
+	defer stopAccessLogged(s)
+
+	// Act
+	err := postEcho(s, "hello")
+	waitFor(func() bool { return logs.contains("POST") })
+	var entry map[string]interface{}
+	jsonErr := json.Unmarshal([]byte(logs.String()), &entry)
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(jsonErr)
+	assert.Equal("/echo/:name", entry["route"])
+	assert.Equal(float64(5), entry["bytesIn"])
+	assert.Equal("access-1", entry["requestId"])
+	assert.Equal("http://example.com/", entry["referer"])
+	assert.True(strings.HasPrefix(logs.String(), `{"time":`))
+}
+
+func testServer_AccessLog_Logfmt(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogLogfmt)
+	defer stopAccessLogged(s)
+
+	// Act
+	err := postEcho(s, "hello world")
+	waitFor(func() bool { return logs.contains("POST") })
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(logs.String(), " method=POST path=\"/echo/test?q=1\" route=/echo/:name proto=HTTP/1.1 status=200 latencyMs=")
+	assert.Contains(logs.String(), " bytesIn=11 bytesOut=11 clientIp=127.0.0.1 userAgent=test-agent/1.0 referer=http://example.com/ requestId=access-1\n")
+}
+
+func testServer_AccessLog_Exclude(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogCommon, srv.OptionAccessLogExclude("/_system/liveness", "/echo/*"))
+	defer stopAccessLogged(s)
+
+	// Act
+	liveness, livenessErr := http.Get("http://" + s.Addr().String() + "/_system/liveness")
+	liveness.Body.Close()
+	echoErr := postEcho(s, "hello")
+	readiness, readinessErr := http.Get("http://" + s.Addr().String() + "/_system/readiness")
+	readiness.Body.Close()
+	waitFor(func() bool { return logs.contains("readiness") })
+
+	// Assert
+	assert.NoError(livenessErr)
+	assert.NoError(echoErr)
+	assert.NoError(readinessErr)
+	assert.Equal(1, strings.Count(logs.String(), "\n"))
+	assert.Contains(logs.String(), "/_system/readiness")
+}
+
+func testServer_AccessLog_SampleRate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := startAccessLogged(logs, srv.AccessLogCommon, srv.OptionAccessLogSampleRate(0))
+	defer stopAccessLogged(s)
+
+	// Act
+	echoErr := postEcho(s, "hello")
+	fail, failErr := http.Get("http://" + s.Addr().String() + "/fail")
+	fail.Body.Close()
+	waitFor(func() bool { return logs.contains("/fail") })
+
+	// Assert
+	assert.NoError(echoErr)
+	assert.NoError(failErr)
+	assert.Equal(1, strings.Count(logs.String(), "\n"))
+	assert.Contains(logs.String(), `"GET /fail HTTP/1.1" 500`)
+}
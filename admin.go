@@ -0,0 +1,125 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// adminListenerName is the name of the admin listener passed during an upgrade
+const adminListenerName = "admin"
+
+// adminServer holds the separate listener and router the system endpoints
+// are served on when OptionAdminAddr or OptionAdminListener is set
+type adminServer struct {
+	addr     string
+	provided net.Listener
+	router   *httprouter.Router
+
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+// enabled tells if the system endpoints are served on the admin listener
+func (a *adminServer) enabled() bool {
+	return a.addr != "" || a.provided != nil
+}
+
+// handleSystem registers a /_system endpoint on the admin router when it is enabled
+// and otherwise on the main router under the context path
+func (s *Server) handleSystem(method, path string, handle httprouter.Handle) {
+	if s.admin.enabled() {
+		if s.admin.router == nil {
+			s.admin.router = httprouter.New()
+			s.admin.router.HandleMethodNotAllowed = true
+			s.admin.router.MethodNotAllowed = MethodNotAllowedHandler()
+			s.admin.router.NotFound = NotFoundHandler()
+			s.admin.router.PanicHandler = s.PanicHandler
+		}
+
+		s.admin.router.Handle(method, path, handle)
+		return
+	}
+
+	s.Handle(method, path, handle)
+}
+
+// AdminAddr returns the address the admin listener is bound to or nil when the
+// server is not running or the system endpoints are served on the main listener
+func (s *Server) AdminAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.admin.listener == nil {
+		return nil
+	}
+
+	return s.admin.listener.Addr()
+}
+
+// startAdmin binds the admin listener and serves the system endpoints in a goroutine,
+// the caller must hold the lock
+func (s *Server) startAdmin() error {
+	if !s.admin.enabled() {
+		return nil
+	}
+
+	l := s.admin.provided
+	if l == nil {
+		var err error
+		if l, err = s.listenAdmin(); err != nil {
+			return errors.New("common/server: failed to start admin server: " + err.Error())
+		}
+	}
+
+	httpServer := s.http.newHTTPServer(l.Addr().String(), s.admin.router)
+	s.admin.httpServer = httpServer
+	s.admin.listener = l
+
+	go func() {
+		s.Logger().Info("Starting admin HTTP server", "addr", httpServer.Addr)
+		if err := httpServer.Serve(l); err != nil && err != http.ErrServerClosed {
+			s.Logger().Error("Admin HTTP server failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// listenAdmin returns the admin listener inherited from the parent process during an upgrade
+// or binds the admin addr. The socket activation descriptor is only used by the main listener.
+func (s *Server) listenAdmin() (net.Listener, error) {
+	if l, err := inheritedListener(adminListenerName); l != nil || err != nil {
+		return l, err
+	}
+
+	return s.bind(s.admin.addr)
+}
+
+// shutdownAdmin gracefully stops the admin server
+func (s *Server) shutdownAdmin(ctx context.Context) error {
+	s.mu.Lock()
+	httpServer := s.admin.httpServer
+	s.mu.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+
+	return httpServer.Shutdown(ctx)
+}
+
+// stoppedAdmin closes the admin server and clears its running state, the caller must hold the lock
+func (s *Server) stoppedAdmin() {
+	if s.admin.httpServer == nil {
+		return
+	}
+
+	s.admin.httpServer.Close()
+	s.admin.listener.Close()
+	s.admin.httpServer = nil
+	s.admin.listener = nil
+}
@@ -0,0 +1,89 @@
+package srv_test
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_Admin(t *testing.T) {
+	t.Run("AdminAddr", testServer_Admin_AdminAddr)
+	t.Run("AdminListener", testServer_Admin_AdminListener)
+	t.Run("ListenError", testServer_Admin_ListenError)
+	t.Run("SocketActivation", testServer_Admin_SocketActivation)
+}
+
+func testServer_Admin_AdminAddr(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionAdminAddr("127.0.0.1:0"), srv.OptionContextPath("/api"))
+
+	// Act
+	err := s.Start("127.0.0.1:0")
+	adminRes, adminErr := http.Get("http://" + s.AdminAddr().String() + "/_system/readiness")
+	publicRes, publicErr := http.Get("http://" + s.Addr().String() + "/api/_system/readiness")
+	s.Shutdown()
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(adminErr)
+	assert.NoError(publicErr)
+	assert.Equal(http.StatusOK, adminRes.StatusCode)
+	assert.Equal(http.StatusNotFound, publicRes.StatusCode)
+	assert.Nil(s.AdminAddr())
+}
+
+func testServer_Admin_AdminListener(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := srv.New(srv.OptionAdminListener(l))
+
+	// Act
+	err := s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+	res, getErr := http.Get("http://" + l.Addr().String() + "/_system/liveness")
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(getErr)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(l.Addr(), s.AdminAddr())
+}
+
+func testServer_Admin_ListenError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	s := srv.New(srv.OptionAdminAddr(l.Addr().String()))
+
+	// Act
+	err := s.Start("127.0.0.1:0")
+
+	// Assert
+	assert.Error(err)
+	assert.False(s.IsRunning())
+}
+
+func testServer_Admin_SocketActivation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := srv.New(srv.OptionSocketActivation(), srv.OptionAdminAddr("127.0.0.1:0"))
+	errCh := make(chan error, 1)
+
+	// Act
+	go func() { errCh <- s.Serve(l) }()
+	waitFor(func() bool { return s.IsRunning() || len(errCh) > 0 })
+	adminAddr := s.AdminAddr()
+	s.Shutdown()
+
+	// Assert
+	assert.NoError(<-errCh)
+	assert.NotNil(adminAddr)
+}
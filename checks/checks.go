@@ -0,0 +1,37 @@
+// Package checks provides health metric handlers for common dependencies that can
+// be added to the server with AddReadinessCheck, AddLivenessCheck and AddStartupCheck.
+// Every check reports how long it took in the "latency" info field.
+package checks
+
+import (
+	"time"
+
+	"github.com/go-nm/srv"
+)
+
+// latencyResult builds the result of a check against a remote dependency, observing
+// the response time since start
+func latencyResult(start time.Time, err error) srv.HealthMetricResult {
+	latency := time.Since(start)
+
+	res := result(latency, err)
+	res.Measurement = "responseTime"
+	res.ObservedValue = float64(latency) / float64(time.Millisecond)
+	res.ObservedUnit = "ms"
+
+	return res
+}
+
+// result builds the result of a check that took latency, it is not ok when err is set
+func result(latency time.Duration, err error) srv.HealthMetricResult {
+	res := srv.HealthMetricResult{
+		OK:   err == nil,
+		Info: map[string]interface{}{"latency": latency.String()},
+	}
+
+	if err != nil {
+		res.Info["error"] = err.Error()
+	}
+
+	return res
+}
@@ -0,0 +1,28 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-nm/srv"
+)
+
+// DiskFree checks the file system containing path has at least minBytes available
+func DiskFree(path string, minBytes uint64) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+		free, err := diskFree(path)
+		if err == nil && free < minBytes {
+			err = fmt.Errorf("%d bytes free is below the minimum of %d", free, minBytes)
+		}
+
+		res := result(time.Since(start), err)
+		res.Measurement = "free"
+		res.ObservedValue = free
+		res.ObservedUnit = "bytes"
+		res.Info["freeBytes"] = free
+
+		return res
+	}
+}
@@ -0,0 +1,63 @@
+package checks_test
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv/checks"
+)
+
+func TestDiskFree(t *testing.T) {
+	t.Run("Success", testDiskFree_Success)
+	t.Run("BelowMinimum", testDiskFree_BelowMinimum)
+	t.Run("MissingPath", testDiskFree_MissingPath)
+}
+
+func testDiskFree_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "checks-disk")
+	defer os.RemoveAll(dir)
+
+	// Act
+	got := checks.DiskFree(dir, 1)(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotZero(got.Info["freeBytes"])
+	assert.NotEmpty(got.Info["latency"])
+}
+
+func testDiskFree_BelowMinimum(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "checks-disk")
+	defer os.RemoveAll(dir)
+
+	// Act
+	got := checks.DiskFree(dir, math.MaxUint64)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
+
+func testDiskFree_MissingPath(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "checks-disk")
+	os.RemoveAll(dir)
+
+	// Act
+	got := checks.DiskFree(filepath.Join(dir, "missing"), 1)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
@@ -0,0 +1,80 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-nm/srv"
+)
+
+// TCPDial checks a TCP connection can be opened to addr
+func TCPDial(addr string) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+
+		return latencyResult(start, err)
+	}
+}
+
+// HTTPGet checks a GET request to url responds with the expected status code
+func HTTPGet(url string, expectedStatus int) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+		status, err := httpGet(ctx, url)
+		if err == nil && status != expectedStatus {
+			err = fmt.Errorf("unexpected status code %d, expected %d", status, expectedStatus)
+		}
+
+		res := latencyResult(start, err)
+		if status != 0 {
+			res.Info["statusCode"] = status
+		}
+
+		return res
+	}
+}
+
+// httpGet requests url and returns the status code of the response
+func httpGet(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Read the body so the connection can be reused by the next check
+	io.Copy(ioutil.Discard, res.Body)
+
+	return res.StatusCode, nil
+}
+
+// DNSResolve checks host resolves to at least one address
+func DNSResolve(host string) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+
+		res := latencyResult(start, err)
+		if err == nil {
+			res.Info["addresses"] = addrs
+		}
+
+		return res
+	}
+}
@@ -0,0 +1,133 @@
+package checks_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv/checks"
+)
+
+func TestTCPDial(t *testing.T) {
+	t.Run("Success", testTCPDial_Success)
+	t.Run("Failure", testTCPDial_Failure)
+}
+
+func testTCPDial_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	// Act
+	got := checks.TCPDial(l.Addr().String())(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotEmpty(got.Info["latency"])
+	assert.Equal("ms", got.ObservedUnit)
+}
+
+func testTCPDial_Failure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	// Act
+	got := checks.TCPDial(addr)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
+
+func TestHTTPGet(t *testing.T) {
+	t.Run("Success", testHTTPGet_Success)
+	t.Run("UnexpectedStatus", testHTTPGet_UnexpectedStatus)
+	t.Run("Unreachable", testHTTPGet_Unreachable)
+}
+
+func testHTTPGet_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	// Act
+	got := checks.HTTPGet(ts.URL, http.StatusNoContent)(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.Equal(http.StatusNoContent, got.Info["statusCode"])
+	assert.NotEmpty(got.Info["latency"])
+}
+
+func testHTTPGet_UnexpectedStatus(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// Act
+	got := checks.HTTPGet(ts.URL, http.StatusOK)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.Equal(http.StatusServiceUnavailable, got.Info["statusCode"])
+	assert.Equal("unexpected status code 503, expected 200", got.Info["error"])
+}
+
+func testHTTPGet_Unreachable(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	// Act
+	got := checks.HTTPGet(ts.URL, http.StatusOK)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.Nil(got.Info["statusCode"])
+	assert.NotEmpty(got.Info["error"])
+}
+
+func TestDNSResolve(t *testing.T) {
+	t.Run("Success", testDNSResolve_Success)
+	t.Run("Failure", testDNSResolve_Failure)
+}
+
+func testDNSResolve_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.DNSResolve("localhost")(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotEmpty(got.Info["addresses"])
+	assert.NotEmpty(got.Info["latency"])
+}
+
+func testDNSResolve_Failure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.DNSResolve("")(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
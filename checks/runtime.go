@@ -0,0 +1,53 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/go-nm/srv"
+)
+
+// HeapBelow checks the bytes of allocated heap objects stay below the limit
+func HeapBelow(bytes uint64) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+
+		var err error
+		if m.HeapAlloc >= bytes {
+			err = fmt.Errorf("heap of %d bytes is not below %d", m.HeapAlloc, bytes)
+		}
+
+		res := result(time.Since(start), err)
+		res.Measurement = "heapAlloc"
+		res.ObservedValue = m.HeapAlloc
+		res.ObservedUnit = "bytes"
+		res.Info["heapAlloc"] = m.HeapAlloc
+
+		return res
+	}
+}
+
+// GoroutinesBelow checks the number of goroutines stays below n
+func GoroutinesBelow(n int) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+		count := runtime.NumGoroutine()
+
+		var err error
+		if count >= n {
+			err = fmt.Errorf("%d goroutines is not below %d", count, n)
+		}
+
+		res := result(time.Since(start), err)
+		res.Measurement = "goroutines"
+		res.ObservedValue = count
+		res.Info["goroutines"] = count
+
+		return res
+	}
+}
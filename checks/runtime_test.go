@@ -0,0 +1,71 @@
+package checks_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv/checks"
+)
+
+func TestHeapBelow(t *testing.T) {
+	t.Run("Success", testHeapBelow_Success)
+	t.Run("Failure", testHeapBelow_Failure)
+}
+
+func testHeapBelow_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.HeapBelow(math.MaxUint64)(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotZero(got.Info["heapAlloc"])
+	assert.NotEmpty(got.Info["latency"])
+}
+
+func testHeapBelow_Failure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.HeapBelow(1)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
+
+func TestGoroutinesBelow(t *testing.T) {
+	t.Run("Success", testGoroutinesBelow_Success)
+	t.Run("Failure", testGoroutinesBelow_Failure)
+}
+
+func testGoroutinesBelow_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.GoroutinesBelow(math.MaxInt32)(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotZero(got.Info["goroutines"])
+	assert.NotEmpty(got.Info["latency"])
+}
+
+func testGoroutinesBelow_Failure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := checks.GoroutinesBelow(1)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.NotEmpty(got.Info["error"])
+}
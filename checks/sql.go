@@ -0,0 +1,19 @@
+package checks
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/go-nm/srv"
+)
+
+// SQLPing checks the database connection is alive by pinging it
+func SQLPing(db *sql.DB) srv.HealthMetricHandler {
+	return func(ctx context.Context) srv.HealthMetricResult {
+		start := time.Now()
+		err := db.PingContext(ctx)
+
+		return latencyResult(start, err)
+	}
+}
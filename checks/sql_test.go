@@ -0,0 +1,75 @@
+package checks_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv/checks"
+)
+
+// pingDriver is a database driver whose connections fail to ping when the dsn is "down"
+type pingDriver struct{}
+
+type pingConn struct {
+	driver.Conn
+	down bool
+}
+
+func (pingDriver) Open(dsn string) (driver.Conn, error) {
+	return &pingConn{down: dsn == "down"}, nil
+}
+
+func (c *pingConn) Ping(ctx context.Context) error {
+	if c.down {
+		return errors.New("database is down")
+	}
+
+	return nil
+}
+
+func (c *pingConn) Close() error {
+	return nil
+}
+
+func init() {
+	sql.Register("checks-ping", pingDriver{})
+}
+
+func TestSQLPing(t *testing.T) {
+	t.Run("Success", testSQLPing_Success)
+	t.Run("Failure", testSQLPing_Failure)
+}
+
+func testSQLPing_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	db, _ := sql.Open("checks-ping", "up")
+	defer db.Close()
+
+	// Act
+	got := checks.SQLPing(db)(context.Background())
+
+	// Assert
+	assert.True(got.OK)
+	assert.NotEmpty(got.Info["latency"])
+	assert.Equal("responseTime", got.Measurement)
+}
+
+func testSQLPing_Failure(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	db, _ := sql.Open("checks-ping", "down")
+	defer db.Close()
+
+	// Act
+	got := checks.SQLPing(db)(context.Background())
+
+	// Assert
+	assert.False(got.OK)
+	assert.Equal("database is down", got.Info["error"])
+}
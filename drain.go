@@ -0,0 +1,61 @@
+package srv
+
+import (
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Draining tells if the server is in the pre-stop drain phase where the readiness
+// endpoint reports "draining" while the listener keeps serving requests
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// drain fails the readiness endpoint for the drain delay so load balancers stop routing
+// new traffic before the listener is closed. The delay is cut short when another signal
+// is received on interrupt or the server stops on its own.
+func (s *Server) drain(interrupt <-chan os.Signal) {
+	if s.drainDelay <= 0 {
+		return
+	}
+
+	atomic.StoreInt32(&s.draining, 1)
+	s.Logger().Info("Draining HTTP server", "delay", s.drainDelay.String())
+
+	if s.http.disableKeepAlivesOnDrain {
+		s.mu.Lock()
+		if s.httpServer != nil {
+			s.httpServer.SetKeepAlivesEnabled(false)
+		}
+		s.mu.Unlock()
+	}
+
+	timer := time.NewTimer(s.drainDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-interrupt:
+	case <-s.Done():
+	}
+}
+
+// drainAwareHandler reports the readiness as not ok with a "draining" status while
+// the server is draining and otherwise calls the next handler
+func (s *Server) drainAwareHandler(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !s.Draining() {
+			next(w, r, ps)
+			return
+		}
+
+		sendHealth(w, r, s.healthContentType, healthFail, HealthResponse{
+			Status:  "not ok",
+			Metrics: map[string]HealthMetricResult{"server": {Status: "draining"}},
+		})
+	}
+}
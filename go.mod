@@ -1,5 +1,7 @@
 module github.com/go-nm/srv
 
+go 1.21
+
 require (
 	code.cloudfoundry.org/bytefmt v0.0.0-20180906201452-2aa6f33b730c
 	github.com/go-nm/jres v0.0.1
@@ -9,3 +11,8 @@ require (
 	github.com/stretchr/testify v1.3.0
 	github.com/urfave/negroni v1.0.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,80 @@
+package srv
+
+import (
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Group registers routes on the server that share a path prefix and middleware
+type Group struct {
+	server     *Server
+	prefix     string
+	middleware []middleware
+}
+
+// Group creates a group of routes under the prefix, after the context path, such as "/v1/admin".
+// The middleware runs only for the routes of the group, in the order given, once the route is
+// matched. It can be a standard func(http.Handler) http.Handler, a negroni.Handler or a
+// negroni.HandlerFunc and the route params are available with httprouter.ParamsFromContext.
+func (s *Server) Group(prefix string, middleware ...interface{}) *Group {
+	return newGroup(s, strings.TrimSuffix(prefix, "/"), nil, middleware)
+}
+
+// Group creates a group nested under this group, its routes are prefixed with both prefixes
+// and run the middleware of this group before the middleware given
+func (g *Group) Group(prefix string, middleware ...interface{}) *Group {
+	return newGroup(g.server, g.prefix+strings.TrimSuffix(prefix, "/"), g.middleware, middleware)
+}
+
+// newGroup creates a group with the middleware of the parent group followed by the middleware given
+func newGroup(s *Server, prefix string, parent []middleware, add []interface{}) *Group {
+	g := &Group{server: s, prefix: prefix}
+	g.middleware = append(g.middleware, parent...)
+	for _, m := range add {
+		g.middleware = append(g.middleware, newMiddleware(middlewareName(m), m))
+	}
+
+	return g
+}
+
+// Handle registers the handle for the method and the path under the prefix of the group.
+// The route options apply after the middleware of the group.
+func (g *Group) Handle(method, path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.server.handle(method, g.prefix+path, g.middleware, handle, opts)
+}
+
+// GET is a shortcut for group.Handle("GET", path, handle, opts...)
+func (g *Group) GET(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("GET", path, handle, opts...)
+}
+
+// POST is a shortcut for group.Handle("POST", path, handle, opts...)
+func (g *Group) POST(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("POST", path, handle, opts...)
+}
+
+// PUT is a shortcut for group.Handle("PUT", path, handle, opts...)
+func (g *Group) PUT(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("PUT", path, handle, opts...)
+}
+
+// PATCH is a shortcut for group.Handle("PATCH", path, handle, opts...)
+func (g *Group) PATCH(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("PATCH", path, handle, opts...)
+}
+
+// DELETE is a shortcut for group.Handle("DELETE", path, handle, opts...)
+func (g *Group) DELETE(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("DELETE", path, handle, opts...)
+}
+
+// HEAD is a shortcut for group.Handle("HEAD", path, handle, opts...)
+func (g *Group) HEAD(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("HEAD", path, handle, opts...)
+}
+
+// OPTIONS is a shortcut for group.Handle("OPTIONS", path, handle, opts...)
+func (g *Group) OPTIONS(path string, handle httprouter.Handle, opts ...RouteOption) {
+	g.Handle("OPTIONS", path, handle, opts...)
+}
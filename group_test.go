@@ -0,0 +1,100 @@
+package srv_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_Group(t *testing.T) {
+	t.Run("Routes", testServer_Group_Routes)
+	t.Run("Middleware", testServer_Group_Middleware)
+	t.Run("Nested", testServer_Group_Nested)
+}
+
+// tagMiddleware returns a standard middleware adding the tag to the X-Middleware response header
+func tagMiddleware(tag string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", tag)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func testServer_Group_Routes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionAppEnv("dev"), srv.OptionContextPath("/api"))
+	admin := s.Group("/v1/admin/")
+	admin.GET("/users", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	admin.Group("/reports").POST("/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/api/_system/routes")
+	var routes []srv.RouteInfo
+	decodeJres(res.StatusCode, res.Body, &routes)
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(routes, srv.RouteInfo{Method: "GET", Path: "/api/v1/admin/users"})
+	assert.Contains(routes, srv.RouteInfo{Method: "POST", Path: "/api/v1/admin/reports/:id"})
+}
+
+func testServer_Group_Middleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	handle := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Write([]byte(ps.ByName("name")))
+	}
+	s.Group("/admin", tagMiddleware("auth")).GET("/users/:name", handle)
+	s.GET("/users/:name", handle)
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	admin, adminErr := http.Get("http://" + s.Addr().String() + "/admin/users/ada")
+	adminBody, _ := ioutil.ReadAll(admin.Body)
+	public, publicErr := http.Get("http://" + s.Addr().String() + "/users/ada")
+	publicBody, _ := ioutil.ReadAll(public.Body)
+
+	// Assert
+	assert.NoError(adminErr)
+	assert.NoError(publicErr)
+	assert.Equal("auth", admin.Header.Get("X-Middleware"))
+	assert.Equal("ada", string(adminBody))
+	assert.Empty(public.Header.Get("X-Middleware"))
+	assert.Equal("ada", string(publicBody))
+}
+
+func testServer_Group_Nested(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	v1 := s.Group("/v1", tagMiddleware("v1"))
+	admin := v1.Group("/admin", tagMiddleware("admin"))
+	admin.DELETE("/users", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	v1.DELETE("/users", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	adminReq, _ := http.NewRequest("DELETE", "http://"+s.Addr().String()+"/v1/admin/users", nil)
+	adminRes, adminErr := http.DefaultClient.Do(adminReq)
+	v1Req, _ := http.NewRequest("DELETE", "http://"+s.Addr().String()+"/v1/users", nil)
+	v1Res, v1Err := http.DefaultClient.Do(v1Req)
+
+	// Assert
+	assert.NoError(adminErr)
+	assert.NoError(v1Err)
+	assert.Equal([]string{"v1", "admin"}, adminRes.Header.Values("X-Middleware"))
+	assert.Equal([]string{"v1"}, v1Res.Header.Values("X-Middleware"))
+}
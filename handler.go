@@ -1,12 +1,12 @@
 package srv
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"runtime/debug"
-	"sync"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
@@ -17,19 +17,35 @@ import (
 
 // HealthMetricResult is the returning struct for calling the HealthMetricHandler
 type HealthMetricResult struct {
-	OK     bool                   `json:"-"`              // if false the service will return an error on the health endpoint
-	Status string                 `json:"status"`         // an optional status string to use instead of the default "ok" and "not ok"
-	Info   map[string]interface{} `json:"info,omitempty"` // additional information about the health (such as response time, uptime, etc.)
+	OK       bool                   `json:"-"`              // if false the service will return an error on the health endpoint
+	Degraded bool                   `json:"-"`              // if true the check is reported as degraded without failing the health endpoint, even if OK is false
+	Status   string                 `json:"status"`         // an optional status string to use instead of the default "ok", "degraded" and "not ok"
+	Info     map[string]interface{} `json:"info,omitempty"` // additional information about the health (such as response time, uptime, etc.)
+
+	Measurement   string      `json:"-"` // the measurement the check observed (such as "responseTime") used in the application/health+json check key
+	ObservedValue interface{} `json:"-"` // the value the check observed reported in the application/health+json format
+	ObservedUnit  string      `json:"-"` // the unit of the observed value (such as "ms") reported in the application/health+json format
+
+	Age            string     `json:"age,omitempty"`            // how long ago a cached or polled result was checked, set by the server
+	LastTransition *time.Time `json:"lastTransition,omitempty"` // when the check last changed between ok, degraded and not ok, set by the server
+
+	checkedAt time.Time
 }
 
 // HealthMetricHandler is the handler func that needs to be implemented
-// for adding custom health checks.
-type HealthMetricHandler func() HealthMetricResult
+// for adding custom health checks. The context is cancelled when the check
+// times out or the probe request goes away.
+type HealthMetricHandler func(ctx context.Context) HealthMetricResult
 
 // HealthMetric is the struct for a health metric
 type HealthMetric struct {
 	Name     string
 	GetValue HealthMetricHandler
+	Timeout  time.Duration // the amount of time the check can run, zero uses the handler default
+
+	NonCritical bool // if true the check failing is reported as degraded without failing the health endpoint
+
+	state *healthCheckState
 }
 
 // HealthResponse is the response model for the HealthHandler endpoint
@@ -39,47 +55,40 @@ type HealthResponse struct {
 	Metrics map[string]HealthMetricResult `json:"metrics"`
 }
 
-// HealthHandler returns basic system health information
+// HealthHandler returns basic system health information. Each metric is checked
+// concurrently with a default timeout of 5 seconds. The status is the worst state
+// of the metrics, with degraded metrics still returning 200. The response is in the
+// application/health+json format when the request accepts it.
 func HealthHandler(metrics *[]HealthMetric) httprouter.Handle {
+	return healthHandler(func() []HealthMetric {
+		if metrics == nil {
+			return nil
+		}
+		return append([]HealthMetric{}, *metrics...)
+	}, defaultHealthCheckTimeout, defaultHealthContentType)
+}
+
+// healthHandler returns the health of the metrics listed, each one checked with
+// the default timeout unless the metric sets its own
+func healthHandler(list func() []HealthMetric, timeout time.Duration, contentType string) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		res := HealthResponse{}
-		isOk := true
-
-		if metrics != nil {
-			res.Metrics = map[string]HealthMetricResult{}
+		state := healthPass
 
-			wg := sync.WaitGroup{}
-			wg.Add(len(*metrics))
-
-			for _, metric := range *metrics {
-				go func(metric HealthMetric) {
-					defer wg.Done()
-					data := metric.GetValue()
-
-					if data.OK && data.Status == "" {
-						data.Status = "ok"
-					} else if !data.OK && data.Status == "" {
-						data.Status = "not ok"
-					}
-
-					if !data.OK {
-						isOk = false
-					}
-
-					res.Metrics[metric.Name] = data
-				}(metric)
-			}
-
-			wg.Wait()
+		if metrics := list(); metrics != nil {
+			res.Metrics, state = checkHealth(r.Context(), metrics, timeout)
 		}
 
-		if !isOk {
+		switch state {
+		case healthFail:
 			res.Status = "not ok"
-			jres.Send(w, http.StatusInternalServerError, res)
-		} else {
+		case healthWarn:
+			res.Status = "degraded"
+		default:
 			res.Status = "ok"
-			jres.OK(w, res)
 		}
+
+		sendHealth(w, r, contentType, state, res)
 	}
 }
 
@@ -111,10 +120,17 @@ type InfoResponse struct {
 	GC         InfoResponseGC `json:"gc"`
 
 	Metrics map[string]interface{} `json:"metrics"`
+	Routes  []RouteMetrics         `json:"routes,omitempty"`
 }
 
 // InfoHandler returns basic system runtime information
 func InfoHandler(metrics *[]InfoMetric) httprouter.Handle {
+	return infoHandler(metrics, nil)
+}
+
+// infoHandler returns the system runtime information along with the request
+// metrics of the routes listed when there is a list
+func infoHandler(metrics *[]InfoMetric, routes func() []RouteMetrics) httprouter.Handle {
 	cpus := runtime.NumCPU()
 	startTime := time.Now()
 
@@ -137,8 +153,14 @@ func InfoHandler(metrics *[]InfoMetric) httprouter.Handle {
 			Metrics: make(map[string]interface{}),
 		}
 
-		for _, m := range *metrics {
-			resp.Metrics[m.Name] = m.GetValue()
+		if metrics != nil {
+			for _, m := range *metrics {
+				resp.Metrics[m.Name] = m.GetValue()
+			}
+		}
+
+		if routes != nil {
+			resp.Routes = routes()
 		}
 
 		jres.OK(w, resp)
@@ -147,8 +169,10 @@ func InfoHandler(metrics *[]InfoMetric) httprouter.Handle {
 
 // RouteInfo is the response object for a single route info object
 type RouteInfo struct {
-	Method string `json:"method"`
-	Path   string `json:"path"`
+	Method     string                 `json:"method"`
+	Path       string                 `json:"path"`
+	Middleware []string               `json:"middleware,omitempty"` // the group and route middleware in the order it runs
+	Options    map[string]interface{} `json:"options,omitempty"`    // the route options applied such as "timeout" and "maxBodyBytes"
 }
 
 // RouteHandler returns the handler for listing out the avaliable
@@ -163,7 +187,7 @@ func RouteHandler(routes *[]RouteInfo) httprouter.Handle {
 // This is used as the default handler for the httprouter not found interface
 func NotFoundHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		jres.NotFound(w, "")
+		sendError(w, r, http.StatusNotFound, "", nil)
 	}
 }
 
@@ -171,7 +195,7 @@ func NotFoundHandler() http.HandlerFunc {
 // is not allowed. This is used to override the default httprouter handler
 func MethodNotAllowedHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		jres.MethodNotAllwed(w, nil)
+		sendError(w, r, http.StatusMethodNotAllowed, "method not allowed", nil)
 	}
 }
 
@@ -180,8 +204,58 @@ func MethodNotAllowedHandler() http.HandlerFunc {
 // application during an exception
 func PanicHandler() func(http.ResponseWriter, *http.Request, interface{}) {
 	return func(w http.ResponseWriter, r *http.Request, ctx interface{}) {
-		log.Printf("[PANIC] caught error: %s - stacktrace: %s", ctx, string(debug.Stack()))
+		handlePanic(nil, w, r, ctx)
+	}
+}
+
+// panicHandler is the PanicHandler of the server routers which logs with the server logger
+// when the request logger middleware did not run
+func (s *Server) panicHandler(w http.ResponseWriter, r *http.Request, ctx interface{}) {
+	handlePanic(s.logger, w, r, ctx)
+}
+
+// recovery is the middleware that recovers panics outside of the router, such as in the
+// middleware, and responds like the PanicHandler of the server
+func (s *Server) recovery(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	defer func() {
+		if err := recover(); err != nil {
+			handlePanic(s.logger, w, r, err)
+		}
+	}()
+
+	next(w, r)
+}
+
+// handlePanic logs the panic with the request logger, or with the logger and the request id
+// when the request has no request logger, and responds with a server error
+func handlePanic(logger *slog.Logger, w http.ResponseWriter, r *http.Request, ctx interface{}) {
+	l, ok := r.Context().Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		l = loggerOrDefault(logger)
+		if id := RequestIDFromContext(r.Context()); id != "" {
+			l = l.With("requestId", id)
+		}
+	}
+	l.Error("Caught panic", "error", fmt.Sprint(ctx), "stacktrace", string(debug.Stack()))
+
+	sendError(w, r, http.StatusInternalServerError, "internal server error", nil)
+}
+
+// errorResponse is the jres response body of an error with the request id added to the info
+type errorResponse struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+	Info    interface{} `json:"info"`
 
-		jres.ServerError(w)
+	Errors []string `json:"errors"`
+}
+
+// sendError writes the error in the jres format including the request id when there is one
+func sendError(w http.ResponseWriter, r *http.Request, status int, message string, errors []string) error {
+	res := errorResponse{Message: message, Errors: errors}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		res.Info = map[string]string{"requestId": id}
 	}
+
+	return jres.Send(w, status, res)
 }
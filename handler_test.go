@@ -1,10 +1,12 @@
 package srv_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -19,58 +21,92 @@ func TestHealthHandler(t *testing.T) {
 	infoMetricInfo := map[string]interface{}{"test": "testdata"}
 	customMetricStatus := "awesome"
 	badMetricStatus := "not good at all"
-	baseMetric := srv.HealthMetric{Name: metricName, GetValue: func() srv.HealthMetricResult {
+	baseMetric := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: true}
 	}}
-	customStatusMetric := srv.HealthMetric{Name: metricName, GetValue: func() srv.HealthMetricResult {
+	customStatusMetric := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: true, Status: customMetricStatus}
 	}}
-	infoMetric := srv.HealthMetric{Name: metricName, GetValue: func() srv.HealthMetricResult {
+	infoMetric := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: true, Info: infoMetricInfo}
 	}}
-	badMetric := srv.HealthMetric{Name: metricName, GetValue: func() srv.HealthMetricResult {
+	badMetric := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: false}
 	}}
-	badMetricCustomStatus := srv.HealthMetric{Name: metricName, GetValue: func() srv.HealthMetricResult {
+	badMetricCustomStatus := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: false, Status: badMetricStatus}
 	}}
+	degradedMetric := srv.HealthMetric{Name: metricName, GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: true, Degraded: true}
+	}}
+	nonCriticalBadMetric := srv.HealthMetric{Name: metricName, NonCritical: true, GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	}}
+	otherBadMetric := srv.HealthMetric{Name: "other", GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	}}
 	type args struct {
 		metrics *[]srv.HealthMetric
 	}
 	tests := []struct {
 		name     string
 		args     args
+		wantCode int
 		wantData srv.HealthResponse
 	}{
 		{
 			name:     "SuccessBasic",
+			wantCode: http.StatusOK,
 			wantData: srv.HealthResponse{Status: "ok"},
 		},
 		{
 			name:     "SuccessMetrics",
+			wantCode: http.StatusOK,
 			wantData: srv.HealthResponse{Status: "ok", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: "ok"}}},
 			args:     args{metrics: &[]srv.HealthMetric{baseMetric}},
 		},
 		{
 			name:     "SuccessCustomStatus",
+			wantCode: http.StatusOK,
 			wantData: srv.HealthResponse{Status: "ok", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: customMetricStatus}}},
 			args:     args{metrics: &[]srv.HealthMetric{customStatusMetric}},
 		},
 		{
 			name:     "SuccessMetricInfo",
+			wantCode: http.StatusOK,
 			wantData: srv.HealthResponse{Status: "ok", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: "ok", Info: infoMetricInfo}}},
 			args:     args{metrics: &[]srv.HealthMetric{infoMetric}},
 		},
 		{
 			name:     "FailureBadMetric",
+			wantCode: http.StatusInternalServerError,
 			wantData: srv.HealthResponse{Status: "not ok"},
 			args:     args{metrics: &[]srv.HealthMetric{badMetric}},
 		},
 		{
 			name:     "FailureBadMetricCustomStatus",
+			wantCode: http.StatusInternalServerError,
 			wantData: srv.HealthResponse{Status: "not ok", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: badMetricStatus}}},
 			args:     args{metrics: &[]srv.HealthMetric{badMetricCustomStatus}},
 		},
+		{
+			name:     "SuccessDegraded",
+			wantCode: http.StatusOK,
+			wantData: srv.HealthResponse{Status: "degraded", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: "degraded"}}},
+			args:     args{metrics: &[]srv.HealthMetric{degradedMetric}},
+		},
+		{
+			name:     "SuccessNonCriticalFailure",
+			wantCode: http.StatusOK,
+			wantData: srv.HealthResponse{Status: "degraded", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: "not ok"}}},
+			args:     args{metrics: &[]srv.HealthMetric{nonCriticalBadMetric}},
+		},
+		{
+			name:     "FailureWorstState",
+			wantCode: http.StatusInternalServerError,
+			wantData: srv.HealthResponse{Status: "not ok", Metrics: metricRes{metricName: srv.HealthMetricResult{Status: "degraded"}, "other": srv.HealthMetricResult{Status: "not ok"}}},
+			args:     args{metrics: &[]srv.HealthMetric{degradedMetric, otherBadMetric}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -82,10 +118,11 @@ func TestHealthHandler(t *testing.T) {
 			handler(w, req, nil)
 			resp := w.Result()
 			var data srv.HealthResponse
-			err := json.NewDecoder(resp.Body).Decode(&data)
+			err := decodeJres(resp.StatusCode, resp.Body, &data)
 
 			// Assert
 			assert.NoError(err)
+			assert.Equal(tt.wantCode, resp.StatusCode)
 			assert.Equal(tt.wantData.Status, data.Status)
 
 			for metricKey, metricValue := range tt.wantData.Metrics {
@@ -97,6 +134,123 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+func TestHealthHandler_Checks(t *testing.T) {
+	t.Run("Timeout", testHealthHandler_Checks_Timeout)
+	t.Run("Cancelled", testHealthHandler_Checks_Cancelled)
+	t.Run("Panic", testHealthHandler_Checks_Panic)
+}
+
+func testHealthHandler_Checks_Timeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	release := make(chan struct{})
+	defer close(release)
+	metrics := []srv.HealthMetric{{Name: "hung", Timeout: 10 * time.Millisecond, GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		<-release
+		return srv.HealthMetricResult{OK: true}
+	}}}
+	handler := srv.HealthHandler(&metrics)
+	req := httptest.NewRequest("GET", "http://localhost/_system/health", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler(w, req, nil)
+	var data srv.HealthResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("timeout", data.Metrics["hung"].Status)
+	assert.NotEmpty(data.Metrics["hung"].Info["duration"])
+}
+
+func testHealthHandler_Checks_Cancelled(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	checkCtx := make(chan context.Context, 1)
+	metrics := []srv.HealthMetric{{Name: "slow", GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		checkCtx <- ctx
+		cancel()
+		<-ctx.Done()
+		return srv.HealthMetricResult{OK: true}
+	}}}
+	handler := srv.HealthHandler(&metrics)
+	req := httptest.NewRequest("GET", "http://localhost/_system/health", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler(w, req, nil)
+	var data srv.HealthResponse
+	json.NewDecoder(w.Result().Body).Decode(&data)
+
+	// Assert
+	assert.Equal("cancelled", data.Metrics["slow"].Status)
+	assert.Equal(context.Canceled, (<-checkCtx).Err())
+}
+
+func testHealthHandler_Checks_Panic(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	metrics := []srv.HealthMetric{{Name: "broken", GetValue: func(ctx context.Context) srv.HealthMetricResult {
+		panic("check failed")
+	}}}
+	handler := srv.HealthHandler(&metrics)
+	req := httptest.NewRequest("GET", "http://localhost/_system/health", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	handler(w, req, nil)
+	var data srv.HealthResponse
+	json.NewDecoder(w.Result().Body).Decode(&data)
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("panic", data.Metrics["broken"].Status)
+	assert.Equal("check failed", data.Metrics["broken"].Info["error"])
+}
+
+func TestHealthHandler_HealthJSON(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	metrics := []srv.HealthMetric{
+		{Name: "database", GetValue: func(ctx context.Context) srv.HealthMetricResult {
+			return srv.HealthMetricResult{OK: true, Measurement: "responseTime", ObservedValue: 12, ObservedUnit: "ms"}
+		}},
+		{Name: "cache", NonCritical: true, GetValue: func(ctx context.Context) srv.HealthMetricResult {
+			return srv.HealthMetricResult{OK: false, Status: "unreachable"}
+		}},
+	}
+	handler := srv.HealthHandler(&metrics)
+	req := httptest.NewRequest("GET", "http://localhost/_system/health", nil)
+	req.Header.Set("Accept", "application/health+json, application/json;q=0.9")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler(w, req, nil)
+	var data srv.HealthJSONResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(srv.HealthJSONContentType, w.Header().Get("Content-Type"))
+	assert.Equal("warn", data.Status)
+	if assert.Len(data.Checks["database:responseTime"], 1) {
+		check := data.Checks["database:responseTime"][0]
+		assert.Equal("pass", check.Status)
+		assert.Equal(float64(12), check.ObservedValue)
+		assert.Equal("ms", check.ObservedUnit)
+		assert.NotEmpty(check.Time)
+		assert.Empty(check.Output)
+	}
+	if assert.Len(data.Checks["cache"], 1) {
+		assert.Equal("warn", data.Checks["cache"][0].Status)
+		assert.Equal("unreachable", data.Checks["cache"][0].Output)
+	}
+}
+
 func TestInfoHandler(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
@@ -108,7 +262,7 @@ func TestInfoHandler(t *testing.T) {
 	handler(w, req, nil)
 	resp := w.Result()
 	var data srv.InfoResponse
-	err := json.NewDecoder(resp.Body).Decode(&data)
+	err := decodeJres(resp.StatusCode, resp.Body, &data)
 
 	// Assert
 	assert.NoError(err)
@@ -127,7 +281,7 @@ func TestRouteHandler(t *testing.T) {
 	handler(w, req, nil)
 	resp := w.Result()
 	var data []srv.RouteInfo
-	err := json.NewDecoder(resp.Body).Decode(&data)
+	err := decodeJres(resp.StatusCode, resp.Body, &data)
 
 	// Assert
 	assert.NoError(err)
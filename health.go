@@ -0,0 +1,406 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultHealthCheckTimeout is the default amount of time a single health check
+// can run before it is reported with a "timeout" status
+const defaultHealthCheckTimeout = 5 * time.Second
+
+type healthCheckOptionName int
+
+const (
+	healthCheckOptionTimeout healthCheckOptionName = iota
+	healthCheckOptionCacheTTL
+	healthCheckOptionInterval
+	healthCheckOptionCritical
+)
+
+// healthState is the state of a health metric or endpoint, ordered from best to worst
+type healthState int
+
+const (
+	healthPass healthState = iota
+	healthWarn
+	healthFail
+)
+
+// state of the health metric result
+func (r HealthMetricResult) state() healthState {
+	if r.Degraded {
+		return healthWarn
+	} else if !r.OK {
+		return healthFail
+	}
+
+	return healthPass
+}
+
+// HealthCheckOption is the struct for health check based options
+type HealthCheckOption struct {
+	name  healthCheckOptionName
+	value interface{}
+}
+
+// CheckTimeout is used to set the amount of time the health check can run before it
+// is reported with a "timeout" status. The default is set with OptionHealthCheckTimeout.
+func CheckTimeout(timeout time.Duration) HealthCheckOption {
+	return HealthCheckOption{name: healthCheckOptionTimeout, value: timeout}
+}
+
+// CheckCacheTTL is used to run the health check on demand but serve its last result to
+// every probe for the ttl provided, so frequent probes do not re-run expensive checks.
+func CheckCacheTTL(ttl time.Duration) HealthCheckOption {
+	return HealthCheckOption{name: healthCheckOptionCacheTTL, value: ttl}
+}
+
+// CheckInterval is used to run the health check in the background every interval while the
+// server is running. Probes are served the last result without running the check. While the
+// server is not running the check is run on demand with its last result cached for the interval.
+func CheckInterval(interval time.Duration) HealthCheckOption {
+	return HealthCheckOption{name: healthCheckOptionInterval, value: interval}
+}
+
+// CheckCritical is used to set if the health check failing fails the health endpoint. Failures
+// of non-critical checks are reported as degraded and the endpoint still returns 200.
+// Checks are critical by default.
+func CheckCritical(critical bool) HealthCheckOption {
+	return HealthCheckOption{name: healthCheckOptionCritical, value: critical}
+}
+
+// newHealthMetric creates the health metric with the check options applied
+func newHealthMetric(name string, handler HealthMetricHandler, opts []HealthCheckOption) HealthMetric {
+	metric := HealthMetric{Name: name, GetValue: handler, state: &healthCheckState{}}
+
+	for _, o := range opts {
+		switch o.name {
+		case healthCheckOptionTimeout:
+			metric.Timeout = o.value.(time.Duration)
+		case healthCheckOptionCacheTTL:
+			metric.state.cacheTTL = o.value.(time.Duration)
+		case healthCheckOptionInterval:
+			metric.state.interval = o.value.(time.Duration)
+		case healthCheckOptionCritical:
+			metric.NonCritical = !o.value.(bool)
+		}
+	}
+
+	return metric
+}
+
+// healthChecks is a list of health metrics that is safe to add to while it is being checked
+type healthChecks struct {
+	mu      sync.RWMutex
+	metrics []HealthMetric
+
+	// stop is closed to end the background checks, it is nil when they are not running
+	stop    chan struct{}
+	timeout time.Duration
+
+	// endpoint is the name of the health endpoint reported with the changes sent to onChange
+	endpoint string
+	onChange func(HealthChange)
+}
+
+// add a health metric to the list, starting it in the background if the checks are polling
+func (h *healthChecks) add(metric HealthMetric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	metric.state.name = metric.Name
+	metric.state.endpoint = h.endpoint
+	metric.state.onChange = h.onChange
+	h.metrics = append(h.metrics, metric)
+
+	if h.stop != nil && metric.state.interval > 0 {
+		go metric.state.poll(metric, h.timeout, h.stop)
+	}
+}
+
+// poll starts running the health metrics with an interval in the background
+func (h *healthChecks) poll(timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stop != nil {
+		return
+	}
+
+	h.stop = make(chan struct{})
+	h.timeout = timeout
+
+	for _, metric := range h.metrics {
+		if metric.state.interval > 0 {
+			go metric.state.poll(metric, timeout, h.stop)
+		}
+	}
+}
+
+// stopPolling ends the background health checks
+func (h *healthChecks) stopPolling() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// list returns a copy of the health metrics
+func (h *healthChecks) list() []HealthMetric {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]HealthMetric{}, h.metrics...)
+}
+
+// checkHealth runs all of the health metrics concurrently and collects their results.
+// The overall health is the worst state of the metrics.
+func checkHealth(ctx context.Context, metrics []HealthMetric, defaultTimeout time.Duration) (map[string]HealthMetricResult, healthState) {
+	results := make([]HealthMetricResult, len(metrics))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(metrics))
+
+	for i, metric := range metrics {
+		go func(i int, metric HealthMetric) {
+			defer wg.Done()
+			results[i] = metric.state.check(ctx, metric, defaultTimeout)
+		}(i, metric)
+	}
+
+	wg.Wait()
+
+	state := healthPass
+	res := make(map[string]HealthMetricResult, len(metrics))
+	for i, metric := range metrics {
+		if s := results[i].state(); s > state {
+			state = s
+		}
+		res[metric.Name] = results[i]
+	}
+
+	return res, state
+}
+
+// runHealthCheck runs a single health metric under its timeout. A check that does not
+// return in time, or whose probe request goes away, is reported as not ok without
+// waiting for it to finish. A check that panics is reported as not ok. Non-critical
+// metrics that are not ok are reported as degraded.
+func runHealthCheck(ctx context.Context, metric HealthMetric, defaultTimeout time.Duration) HealthMetricResult {
+	data := callHealthCheck(ctx, metric, defaultTimeout)
+	if metric.NonCritical && !data.OK {
+		data.Degraded = true
+	}
+
+	return data
+}
+
+// callHealthCheck calls the health metric handler under its timeout
+func callHealthCheck(ctx context.Context, metric HealthMetric, defaultTimeout time.Duration) HealthMetricResult {
+	timeout := metric.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resCh := make(chan HealthMetricResult, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				resCh <- HealthMetricResult{OK: false, Status: "panic", Info: map[string]interface{}{"error": fmt.Sprint(err)}}
+			}
+		}()
+
+		resCh <- metric.GetValue(ctx)
+	}()
+
+	select {
+	case data := <-resCh:
+		data.checkedAt = time.Now()
+		if data.Degraded && data.Status == "" {
+			data.Status = "degraded"
+		} else if data.OK && data.Status == "" {
+			data.Status = "ok"
+		} else if !data.OK && data.Status == "" {
+			data.Status = "not ok"
+		}
+
+		return data
+
+	case <-ctx.Done():
+		status := "timeout"
+		if ctx.Err() == context.Canceled {
+			status = "cancelled"
+		}
+
+		return HealthMetricResult{OK: false, Status: status, checkedAt: time.Now(), Info: map[string]interface{}{
+			"duration": time.Since(start).Round(time.Millisecond).String(),
+		}}
+	}
+}
+
+// healthCheckState is the last result of a health metric added to the server
+type healthCheckState struct {
+	cacheTTL time.Duration
+	interval time.Duration
+
+	name     string
+	endpoint string
+	onChange func(HealthChange)
+
+	mu             sync.Mutex
+	result         *HealthMetricResult
+	checkedAt      time.Time
+	lastTransition time.Time
+
+	// polls is the number of background checks running the metric
+	polls int
+}
+
+// check returns the result of the health metric. Polled metrics return their last result
+// and cached metrics only run when their last result is older than the ttl, which is the
+// interval for a polled metric that is not being polled. Metrics created without the
+// server have no state and are always run.
+func (c *healthCheckState) check(ctx context.Context, metric HealthMetric, defaultTimeout time.Duration) HealthMetricResult {
+	if c == nil {
+		return runHealthCheck(ctx, metric, defaultTimeout)
+	}
+
+	if res, ok := c.last(); ok {
+		return res
+	}
+
+	res := runHealthCheck(ctx, metric, defaultTimeout)
+	if ctx.Err() != nil {
+		// The probe went away so the result says nothing about the health of the metric
+		return res
+	}
+
+	return c.record(res)
+}
+
+// last returns the last result when it can be served without running the metric
+func (c *healthCheckState) last() (HealthMetricResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result == nil {
+		return HealthMetricResult{}, false
+	}
+
+	if c.interval > 0 && c.polls > 0 {
+		return c.describe(*c.result), true
+	}
+
+	ttl := c.cacheTTL
+	if c.interval > 0 {
+		ttl = c.interval
+	}
+	if ttl <= 0 || time.Since(c.checkedAt) >= ttl {
+		return HealthMetricResult{}, false
+	}
+
+	return c.describe(*c.result), true
+}
+
+// latest returns the last recorded result and when its state last changed, if the metric has run
+func (c *healthCheckState) latest() (HealthMetricResult, time.Time, bool) {
+	if c == nil {
+		return HealthMetricResult{}, time.Time{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result == nil {
+		return HealthMetricResult{}, time.Time{}, false
+	}
+
+	return *c.result, c.lastTransition, true
+}
+
+// record the result of running the metric, tracking when its state changed. Changes
+// after the first result are sent to onChange.
+func (c *healthCheckState) record(res HealthMetricResult) HealthMetricResult {
+	c.mu.Lock()
+
+	now := time.Now()
+	var change *HealthChange
+	if c.result == nil || c.result.state() != res.state() {
+		if c.result != nil && c.onChange != nil {
+			change = &HealthChange{Endpoint: c.endpoint, Check: c.name, From: c.result.Status, To: res.Status, Time: now}
+		}
+		c.lastTransition = now
+	}
+
+	c.result = &res
+	c.checkedAt = now
+	res = c.describe(res)
+
+	c.mu.Unlock()
+
+	if change != nil {
+		c.onChange(*change)
+	}
+
+	return res
+}
+
+// describe adds the age and last transition to the result, the caller must hold the lock
+func (c *healthCheckState) describe(res HealthMetricResult) HealthMetricResult {
+	lastTransition := c.lastTransition
+	res.LastTransition = &lastTransition
+
+	if c.cacheTTL > 0 || c.interval > 0 {
+		res.Age = time.Since(c.checkedAt).Round(time.Millisecond).String()
+	}
+
+	return res
+}
+
+// poll runs the metric every interval until stop is closed. A check that is still
+// running when the checks stop is cancelled.
+func (c *healthCheckState) poll(metric HealthMetric, timeout time.Duration, stop <-chan struct{}) {
+	c.mu.Lock()
+	c.polls++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.polls--
+		c.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if res := runHealthCheck(ctx, metric, timeout); ctx.Err() == nil {
+			c.record(res)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
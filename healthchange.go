@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-nm/jres"
+	"github.com/julienschmidt/httprouter"
+)
+
+// defaultHealthHistorySize is the default number of health changes kept for the history endpoint
+const defaultHealthHistorySize = 100
+
+// HealthChange is a health check changing between ok, degraded and not ok
+type HealthChange struct {
+	Endpoint string    `json:"endpoint"` // the health endpoint of the check such as "readiness"
+	Check    string    `json:"check"`    // the name of the check
+	From     string    `json:"from"`     // the status of the check before the change
+	To       string    `json:"to"`       // the status of the check after the change
+	Time     time.Time `json:"time"`     // when the change was seen
+}
+
+// OnHealthChange registers a func that is called when any startup, readiness or liveness
+// check changes between ok, degraded and not ok. The func is called from the goroutine
+// running the check so it should not block.
+func (s *Server) OnHealthChange(fn func(HealthChange)) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	s.healthListeners = append(s.healthListeners, fn)
+}
+
+// HealthHistory returns the most recent health changes, oldest first
+func (s *Server) HealthHistory() []HealthChange {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	return append([]HealthChange{}, s.healthHistory...)
+}
+
+// healthChanged records the change in the bounded history and notifies the listeners
+func (s *Server) healthChanged(change HealthChange) {
+	s.Logger().Info("Health check changed", "endpoint", change.Endpoint, "check", change.Check, "from", change.From, "to", change.To)
+
+	s.healthMu.Lock()
+	if s.healthHistorySize > 0 {
+		s.healthHistory = append(s.healthHistory, change)
+		if over := len(s.healthHistory) - s.healthHistorySize; over > 0 {
+			s.healthHistory = append([]HealthChange(nil), s.healthHistory[over:]...)
+		}
+	}
+	listeners := append([]func(HealthChange){}, s.healthListeners...)
+	s.healthMu.Unlock()
+
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
+
+// healthHistoryHandler returns the most recent health changes, oldest first
+func (s *Server) healthHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	jres.Send(w, http.StatusOK, s.HealthHistory())
+}
@@ -0,0 +1,113 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-nm/jres"
+)
+
+// HealthJSONContentType is the media type of the IETF health check response format
+// (draft-inadarei-api-health-check) served as HealthJSONResponse
+const HealthJSONContentType = "application/health+json"
+
+// defaultHealthContentType is the media type of the HealthResponse format
+const defaultHealthContentType = "application/json"
+
+// HealthJSONResponse is the response model for the health endpoints in the
+// application/health+json format
+type HealthJSONResponse struct {
+	Status string `json:"status"`
+
+	Checks map[string][]HealthJSONCheck `json:"checks,omitempty"`
+}
+
+// HealthJSONCheck is the result of a single health metric in the application/health+json format
+type HealthJSONCheck struct {
+	Status        string      `json:"status"`
+	ObservedValue interface{} `json:"observedValue,omitempty"`
+	ObservedUnit  string      `json:"observedUnit,omitempty"`
+	Time          string      `json:"time,omitempty"`
+	Output        string      `json:"output,omitempty"`
+}
+
+// healthJSONStatus is the application/health+json status for each health state
+var healthJSONStatus = map[healthState]string{
+	healthPass: "pass",
+	healthWarn: "warn",
+	healthFail: "fail",
+}
+
+// sendHealth writes the health response in the format accepted by the request, falling
+// back to the default content type. Failing health returns 500 and otherwise 200, which
+// like the other jres ok responses has the health in the data field.
+func sendHealth(w http.ResponseWriter, r *http.Request, defaultContentType string, state healthState, res HealthResponse) error {
+	code := http.StatusOK
+	if state == healthFail {
+		code = http.StatusInternalServerError
+	}
+
+	if healthContentType(r, defaultContentType) != HealthJSONContentType {
+		if code == http.StatusOK {
+			return jres.OK(w, res)
+		}
+
+		return jres.Send(w, code, res)
+	}
+
+	w.Header().Set("Content-Type", HealthJSONContentType)
+	w.WriteHeader(code)
+
+	return json.NewEncoder(w).Encode(newHealthJSONResponse(state, res))
+}
+
+// healthContentType returns the first health format listed in the Accept header of the request
+func healthContentType(r *http.Request, defaultContentType string) string {
+	for _, accept := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		switch mediaType := strings.TrimSpace(strings.Split(accept, ";")[0]); mediaType {
+		case HealthJSONContentType, defaultHealthContentType:
+			return mediaType
+		}
+	}
+
+	if defaultContentType == "" {
+		return defaultHealthContentType
+	}
+
+	return defaultContentType
+}
+
+// newHealthJSONResponse converts the health response to the application/health+json format.
+// The checks are keyed by the metric name and the measurement of its result if it has one.
+func newHealthJSONResponse(state healthState, res HealthResponse) HealthJSONResponse {
+	data := HealthJSONResponse{Status: healthJSONStatus[state]}
+
+	for name, result := range res.Metrics {
+		check := HealthJSONCheck{
+			Status:        healthJSONStatus[result.state()],
+			ObservedValue: result.ObservedValue,
+			ObservedUnit:  result.ObservedUnit,
+		}
+
+		if !result.checkedAt.IsZero() {
+			check.Time = result.checkedAt.UTC().Format(time.RFC3339)
+		}
+		if result.state() != healthPass {
+			check.Output = result.Status
+		}
+
+		key := name
+		if result.Measurement != "" {
+			key += ":" + result.Measurement
+		}
+
+		if data.Checks == nil {
+			data.Checks = map[string][]HealthJSONCheck{}
+		}
+		data.Checks[key] = append(data.Checks[key], check)
+	}
+
+	return data
+}
@@ -0,0 +1,98 @@
+package srv
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// unixAddrPrefix is the prefix of an addr to listen on a unix domain socket
+const unixAddrPrefix = "unix:"
+
+// sdListenFDsStart is the first file descriptor passed by systemd socket activation
+const sdListenFDsStart = 3
+
+// ErrNoSocketActivation is the error returned when socket activation is enabled
+// but the process was not started with any systemd socket file descriptors
+var ErrNoSocketActivation = errors.New("common/server: no socket activation file descriptors")
+
+// listen on the addr provided. Addresses prefixed with "unix:" are bound as a unix
+// domain socket and the addr is ignored when socket activation is enabled or the
+// listener with the name provided was inherited from the parent process during an upgrade.
+func (s *Server) listen(name, addr string) (net.Listener, error) {
+	if l, err := inheritedListener(name); l != nil || err != nil {
+		return l, err
+	}
+
+	if s.socketActivation {
+		return activatedListener(sdListenFDsStart)
+	}
+
+	return s.bind(addr)
+}
+
+// bind the addr provided as a unix domain socket when it is prefixed with "unix:"
+// and otherwise as a TCP address
+func (s *Server) bind(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, unixAddrPrefix) {
+		return listenUnix(strings.TrimPrefix(addr, unixAddrPrefix), s.unixSocketMode)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix binds a unix domain socket at path. A stale socket left behind by a previous
+// process, which refuses connections, is removed before binding while a socket that is
+// still being served is left in place so binding fails with "address already in use".
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+		} else if errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(path)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// activatedListener returns the first listener passed to the process by systemd
+// socket activation (LISTEN_PID and LISTEN_FDS). The environment variables are
+// unset so the file descriptors are not inherited by child processes.
+func activatedListener(firstFD int) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoSocketActivation
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, ErrNoSocketActivation
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(firstFD), "LISTEN_FD_"+strconv.Itoa(firstFD))
+	defer f.Close()
+
+	return net.FileListener(f)
+}
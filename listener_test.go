@@ -0,0 +1,118 @@
+//go:build !windows
+// +build !windows
+
+package srv
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivatedListener(t *testing.T) {
+	t.Run("Success", testActivatedListener_Success)
+	t.Run("NotActivated", testActivatedListener_NotActivated)
+	t.Run("OtherProcess", testActivatedListener_OtherProcess)
+}
+
+func testActivatedListener_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	f, _ := l.(*net.TCPListener).File()
+	defer f.Close()
+	fd, _ := syscall.Dup(int(f.Fd())) // owned and closed by activatedListener
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+
+	// Act
+	got, err := activatedListener(fd)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(l.Addr().String(), got.Addr().String())
+	assert.Empty(os.Getenv("LISTEN_FDS"))
+	got.Close()
+}
+
+func testActivatedListener_NotActivated(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+
+	// Act
+	_, err := activatedListener(sdListenFDsStart)
+
+	// Assert
+	assert.Equal(ErrNoSocketActivation, err)
+}
+
+func testActivatedListener_OtherProcess(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+
+	// Act
+	_, err := activatedListener(sdListenFDsStart)
+
+	// Assert
+	assert.Equal(ErrNoSocketActivation, err)
+}
+
+func TestListenUnix(t *testing.T) {
+	t.Run("StaleSocket", testListenUnix_StaleSocket)
+	t.Run("ActiveSocket", testListenUnix_ActiveSocket)
+}
+
+func testListenUnix_StaleSocket(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "srv-unix")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "srv.sock")
+	stale, _ := net.Listen("unix", path)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	// Act
+	got, err := listenUnix(path, 0)
+
+	// Assert
+	assert.NoError(err)
+	if got != nil {
+		got.Close()
+	}
+}
+
+func testListenUnix_ActiveSocket(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "srv-unix")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "srv.sock")
+	active, _ := net.Listen("unix", path)
+	defer active.Close()
+
+	// Act
+	_, err := listenUnix(path, 0)
+	conn, dialErr := net.Dial("unix", path)
+
+	// Assert
+	assert.True(errors.Is(err, syscall.EADDRINUSE))
+	assert.NoError(dialErr)
+	if conn != nil {
+		conn.Close()
+	}
+}
@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// loggerKey is the context key of the request logger
+type loggerKey struct{}
+
+// LoggerFromContext returns the logger of the request being served which carries the request id,
+// trace id and route of the request. The default slog logger is returned outside of a request.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// Logger returns the logger used by the server for lifecycle events, panics and access logs
+func (s *Server) Logger() *slog.Logger {
+	return loggerOrDefault(s.logger)
+}
+
+// loggerOrDefault returns the logger or the default slog logger when it is nil
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// requestLogger is the middleware that adds the request logger to the request context
+func (s *Server) requestLogger(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	logger := s.Logger()
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		logger = logger.With("requestId", id)
+	}
+	if sc := SpanContextFromContext(r.Context()); sc.IsValid() {
+		logger = logger.With("traceId", sc.TraceID.String(), "spanId", sc.SpanID.String())
+	}
+
+	next(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+}
+
+// logRoute wraps the handle to add the route template to the request logger and the access log
+func logRoute(route string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if entry, ok := r.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+			entry.route = route
+		}
+		if logger, ok := r.Context().Value(loggerKey{}).(*slog.Logger); ok {
+			r = r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger.With("route", route)))
+		}
+
+		handle(w, r, ps)
+	}
+}
@@ -0,0 +1,258 @@
+package srv_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+// logBuffer collects the JSON log lines written by the server goroutines
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// contains tells if any log line contains the text
+func (b *logBuffer) contains(text string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Contains(b.buf.Bytes(), []byte(text))
+}
+
+// String returns all of the log lines
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// entries returns the log lines with the message given
+func (b *logBuffer) entries(msg string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry["msg"] == msg {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func TestServer_Logger(t *testing.T) {
+	t.Run("Lifecycle", testServer_Logger_Lifecycle)
+	t.Run("RequestLogger", testServer_Logger_RequestLogger)
+	t.Run("Panic", testServer_Logger_Panic)
+	t.Run("PanicWithoutRequestLogger", testServer_Logger_PanicWithoutRequestLogger)
+	t.Run("PanicDevMode", testServer_Logger_PanicDevMode)
+	t.Run("PanicMiddleware", testServer_Logger_PanicMiddleware)
+	t.Run("AccessLog", testServer_Logger_AccessLog)
+	t.Run("Default", testServer_Logger_Default)
+}
+
+func testServer_Logger_Lifecycle(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+
+	// Act
+	s.Start("127.0.0.1:0")
+	addr := s.Addr().String()
+	waitFor(func() bool { return len(logs.entries("Starting HTTP server")) > 0 })
+	s.Shutdown()
+
+	// Assert
+	if started := logs.entries("Starting HTTP server"); assert.Len(started, 1) {
+		assert.Equal("INFO", started[0]["level"])
+		assert.Equal(addr, started[0]["addr"])
+	}
+	assert.Len(logs.entries("Shutting down HTTP server"), 1)
+}
+
+func testServer_Logger_RequestLogger(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))), srv.OptionTracing(srv.NewInMemoryExporter()))
+	var sc srv.SpanContext
+	s.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		sc = srv.SpanContextFromContext(r.Context())
+		srv.LoggerFromContext(r.Context()).Info("Loaded user", "id", ps.ByName("id"))
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/users/1", "logged-1")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	if entries := logs.entries("Loaded user"); assert.Len(entries, 1) {
+		assert.Equal("logged-1", entries[0]["requestId"])
+		assert.Equal(sc.TraceID.String(), entries[0]["traceId"])
+		assert.Equal(sc.SpanID.String(), entries[0]["spanId"])
+		assert.Equal("/users/:id", entries[0]["route"])
+		assert.Equal("1", entries[0]["id"])
+	}
+}
+
+func testServer_Logger_Panic(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("failed")
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	_, err := getWithRequestID(s, "/test", "panic-2")
+
+	// Assert
+	assert.NoError(err)
+	if entries := logs.entries("Caught panic"); assert.Len(entries, 1) {
+		assert.Equal("ERROR", entries[0]["level"])
+		assert.Equal("failed", entries[0]["error"])
+		assert.Equal("panic-2", entries[0]["requestId"])
+		assert.NotEmpty(entries[0]["stacktrace"])
+	}
+}
+
+func testServer_Logger_PanicWithoutRequestLogger(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(
+		srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))),
+		srv.OptionDefaultMiddleware(srv.MiddlewareRequestID),
+	)
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("failed")
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "panic-3")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	if entries := logs.entries("Caught panic"); assert.Len(entries, 1) {
+		assert.Equal("failed", entries[0]["error"])
+		assert.Equal("panic-3", entries[0]["requestId"])
+	}
+}
+
+func testServer_Logger_PanicDevMode(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))), srv.OptionAppEnv("dev"))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("failed")
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "panic-4")
+	var data map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(map[string]interface{}{"requestId": "panic-4"}, data["info"])
+	if entries := logs.entries("Caught panic"); assert.Len(entries, 1) {
+		assert.Equal("failed", entries[0]["error"])
+		assert.Equal("panic-4", entries[0]["requestId"])
+	}
+}
+
+func testServer_Logger_PanicMiddleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("failed")
+		})
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "panic-5")
+	var data map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(map[string]interface{}{"requestId": "panic-5"}, data["info"])
+	if entries := logs.entries("Caught panic"); assert.Len(entries, 1) {
+		assert.Equal("failed", entries[0]["error"])
+		assert.Equal("panic-5", entries[0]["requestId"])
+	}
+}
+
+func testServer_Logger_AccessLog(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logs := &logBuffer{}
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewJSONHandler(logs, nil))))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	_, err := http.Get("http://" + s.Addr().String() + "/_system/liveness")
+	waitFor(func() bool { return logs.contains("/_system/liveness") })
+
+	// Assert
+	assert.NoError(err)
+	assert.True(logs.contains("/_system/liveness"))
+}
+
+func testServer_Logger_Default(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act
+	got := s.Logger()
+
+	// Assert
+	assert.Equal(slog.Default(), got)
+	assert.Equal(slog.Default(), srv.LoggerFromContext(context.Background()))
+}
@@ -0,0 +1,287 @@
+package srv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// metricsContentType is the media type of the Prometheus text exposition format
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// openMetricsContentType is the media type of the OpenMetrics text format
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// processStartTime is when the process started serving as close as the package can tell
+var processStartTime = time.Now()
+
+// metricsWriter writes metric families in the Prometheus text exposition format or
+// in the OpenMetrics format
+type metricsWriter struct {
+	w           io.Writer
+	openMetrics bool
+}
+
+// gauge writes the metadata of a gauge family
+func (m *metricsWriter) gauge(name, help string) {
+	m.family(name, "gauge", help)
+}
+
+// counter writes the metadata of a counter family, its samples must be written with the _total suffix
+func (m *metricsWriter) counter(name, help string) {
+	if m.openMetrics {
+		m.family(name, "counter", help)
+		return
+	}
+
+	m.family(name+"_total", "counter", help)
+}
+
+// histogram writes the metadata of a histogram family
+func (m *metricsWriter) histogram(name, help string) {
+	m.family(name, "histogram", help)
+}
+
+// family writes the help and type lines of a metric family
+func (m *metricsWriter) family(name, typ, help string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single sample of the family, labels are pairs of names and values
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	io.WriteString(m.w, name)
+
+	if len(labels) > 0 {
+		escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+		io.WriteString(m.w, "{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				io.WriteString(m.w, ",")
+			}
+			fmt.Fprintf(m.w, `%s="%s"`, labels[i], escape.Replace(labels[i+1]))
+		}
+		io.WriteString(m.w, "}")
+	}
+
+	fmt.Fprintf(m.w, " %s\n", formatMetricValue(value))
+}
+
+// end finishes the exposition
+func (m *metricsWriter) end() {
+	if m.openMetrics {
+		io.WriteString(m.w, "# EOF\n")
+	}
+}
+
+// formatMetricValue formats the sample value the way Prometheus parses it
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// metricName converts a camel-case name to a snake-case metric name
+// with any characters that are not allowed replaced by an underscore
+func metricName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	return b.String()
+}
+
+// metricsHandler returns the runtime, process, health check, info and route metrics in the
+// Prometheus text exposition format, or in the OpenMetrics format when the request accepts it
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var buf bytes.Buffer
+	m := &metricsWriter{w: &buf, openMetrics: strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")}
+
+	writeRuntimeMetrics(m)
+	writeProcessMetrics(m)
+	s.writeHealthMetrics(m)
+	s.writeInfoMetrics(m)
+	s.requests.write(m)
+	m.end()
+
+	if m.openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", metricsContentType)
+	}
+
+	w.Write(buf.Bytes())
+}
+
+// writeRuntimeMetrics writes the Go runtime and memory stats
+func writeRuntimeMetrics(m *metricsWriter) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+
+	m.gauge("go_info", "Information about the Go environment.")
+	m.sample("go_info", 1, "version", runtime.Version())
+	m.gauge("go_goroutines", "Number of goroutines that currently exist.")
+	m.sample("go_goroutines", float64(runtime.NumGoroutine()))
+	m.gauge("go_threads", "Number of OS threads created.")
+	m.sample("go_threads", float64(threads))
+
+	m.gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.")
+	m.sample("go_memstats_alloc_bytes", float64(stats.Alloc))
+	// OpenMetrics names the counter family without the _total suffix, which would clash with
+	// the gauge, so only that format renames it from the standard go_memstats_alloc_bytes_total
+	allocTotal := "go_memstats_alloc_bytes"
+	if m.openMetrics {
+		allocTotal = "go_memstats_alloc_bytes_cumulative"
+	}
+	m.counter(allocTotal, "Total number of bytes allocated, even if freed.")
+	m.sample(allocTotal+"_total", float64(stats.TotalAlloc))
+	m.gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.")
+	m.sample("go_memstats_sys_bytes", float64(stats.Sys))
+	m.counter("go_memstats_mallocs", "Total number of mallocs.")
+	m.sample("go_memstats_mallocs_total", float64(stats.Mallocs))
+	m.counter("go_memstats_frees", "Total number of frees.")
+	m.sample("go_memstats_frees_total", float64(stats.Frees))
+	m.gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.")
+	m.sample("go_memstats_heap_alloc_bytes", float64(stats.HeapAlloc))
+	m.gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.")
+	m.sample("go_memstats_heap_inuse_bytes", float64(stats.HeapInuse))
+	m.gauge("go_memstats_heap_objects", "Number of allocated objects.")
+	m.sample("go_memstats_heap_objects", float64(stats.HeapObjects))
+	m.gauge("go_memstats_next_gc_bytes", "Number of heap bytes when next garbage collection will take place.")
+	m.sample("go_memstats_next_gc_bytes", float64(stats.NextGC))
+	m.gauge("go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of last garbage collection.")
+	m.sample("go_memstats_last_gc_time_seconds", float64(stats.LastGC)/1e9)
+
+	m.counter("go_gc_cycles", "Number of completed garbage collection cycles.")
+	m.sample("go_gc_cycles_total", float64(stats.NumGC))
+	m.counter("go_gc_pause_seconds", "Total time spent in garbage collection pauses.")
+	m.sample("go_gc_pause_seconds_total", float64(stats.PauseTotalNs)/1e9)
+}
+
+// writeProcessMetrics writes the process stats that are available on the platform
+func writeProcessMetrics(m *metricsWriter) {
+	m.gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.")
+	m.sample("process_start_time_seconds", float64(processStartTime.UnixNano())/1e9)
+
+	writePlatformProcessMetrics(m)
+}
+
+// writeHealthMetrics writes the last result of each startup, readiness and liveness check
+// that has run. The checks are not run to collect the metrics.
+func (s *Server) writeHealthMetrics(m *metricsWriter) {
+	type checkResult struct {
+		endpoint, check string
+		result          HealthMetricResult
+		lastTransition  time.Time
+	}
+
+	var results []checkResult
+	for _, checks := range []*healthChecks{&s.startupMetrics, &s.readinessMetrics, &s.livenessMetrics} {
+		for _, metric := range checks.list() {
+			if res, lastTransition, ok := metric.state.latest(); ok {
+				results = append(results, checkResult{checks.endpoint, metric.Name, res, lastTransition})
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		return
+	}
+
+	m.gauge("srv_health_check_status", "Last result of the health check, 1 for the current status.")
+	for _, r := range results {
+		for _, state := range []healthState{healthPass, healthWarn, healthFail} {
+			value := 0.0
+			if r.result.state() == state {
+				value = 1
+			}
+			m.sample("srv_health_check_status", value, "endpoint", r.endpoint, "check", r.check, "status", healthJSONStatus[state])
+		}
+	}
+
+	m.gauge("srv_health_check_last_transition_timestamp_seconds", "When the health check last changed status since unix epoch in seconds.")
+	for _, r := range results {
+		m.sample("srv_health_check_last_transition_timestamp_seconds", float64(r.lastTransition.UnixNano())/1e9, "endpoint", r.endpoint, "check", r.check)
+	}
+}
+
+// writeInfoMetrics writes the info metrics with numeric values as gauges
+func (s *Server) writeInfoMetrics(m *metricsWriter) {
+	metrics := append([]InfoMetric{}, s.infoMetrics...)
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	for _, metric := range metrics {
+		value, ok := numericValue(metric.GetValue())
+		if !ok {
+			continue
+		}
+
+		name := "srv_info_" + metricName(metric.Name)
+		m.gauge(name, "Value of the "+metric.Name+" info metric.")
+		m.sample(name, value)
+	}
+}
+
+// numericValue returns the value as a float if it is a number
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return v.Seconds(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package srv
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// writePlatformProcessMetrics writes the CPU, memory and file descriptor stats of the process.
+// The stats read from /proc are left out on systems that do not have it.
+func writePlatformProcessMetrics(m *metricsWriter) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err == nil {
+		cpu := float64(usage.Utime.Nano()+usage.Stime.Nano()) / 1e9
+		m.counter("process_cpu_seconds", "Total user and system CPU time spent in seconds.")
+		m.sample("process_cpu_seconds_total", cpu)
+	}
+
+	if stat, err := ioutil.ReadFile("/proc/self/statm"); err == nil {
+		if fields := strings.Fields(string(stat)); len(fields) > 1 {
+			if pages, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				m.gauge("process_resident_memory_bytes", "Resident memory size in bytes.")
+				m.sample("process_resident_memory_bytes", float64(pages*uint64(os.Getpagesize())))
+			}
+		}
+	}
+
+	if fds, err := ioutil.ReadDir("/proc/self/fd"); err == nil {
+		m.gauge("process_open_fds", "Number of open file descriptors.")
+		m.sample("process_open_fds", float64(len(fds)))
+	}
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err == nil {
+		m.gauge("process_max_fds", "Maximum number of open file descriptors.")
+		m.sample("process_max_fds", float64(limit.Cur))
+	}
+}
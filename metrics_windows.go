@@ -0,0 +1,4 @@
+package srv
+
+// writePlatformProcessMetrics writes nothing on windows as the process stats are read from the unix APIs
+func writePlatformProcessMetrics(m *metricsWriter) {}
@@ -0,0 +1,193 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/urfave/negroni"
+
+	"github.com/go-nm/jres"
+)
+
+// DefaultMiddleware is a middleware provided by the server that is installed ahead of the
+// middleware added with Use
+type DefaultMiddleware string
+
+const (
+	// MiddlewareRecovery recovers panics in the middleware and responds with a server error
+	// without the stacktrace
+	MiddlewareRecovery DefaultMiddleware = "recovery"
+	// MiddlewareRequestID adds the request id to the request context and the response
+	MiddlewareRequestID DefaultMiddleware = "requestId"
+	// MiddlewareTracing records the server span of the request when OptionTracing is set
+	MiddlewareTracing DefaultMiddleware = "tracing"
+	// MiddlewareAccessLog writes the access log line of the request
+	MiddlewareAccessLog DefaultMiddleware = "accessLog"
+	// MiddlewareRequestLogger adds the request logger to the request context
+	MiddlewareRequestLogger DefaultMiddleware = "requestLogger"
+	// MiddlewareStatic serves the files of the "public" directory, it is not installed by default
+	MiddlewareStatic DefaultMiddleware = "static"
+)
+
+// defaultMiddleware is the middleware installed when OptionDefaultMiddleware is not set, the
+// request id is added first so the panics recovered are logged and answered with it
+var defaultMiddleware = []DefaultMiddleware{
+	MiddlewareRequestID,
+	MiddlewareRecovery,
+	MiddlewareTracing,
+	MiddlewareAccessLog,
+	MiddlewareRequestLogger,
+}
+
+// middleware is a named entry of the server middleware stack
+type middleware struct {
+	name string
+	wrap func(next http.Handler) http.Handler
+}
+
+// namedMiddleware is a middleware with the name it is listed by
+type namedMiddleware struct {
+	name       string
+	middleware interface{}
+}
+
+// NamedMiddleware gives the middleware the name it is listed by in Middleware and the routes
+// endpoint, such as "auth:admin", instead of the name of its func or type
+func NamedMiddleware(name string, middleware interface{}) interface{} {
+	return namedMiddleware{name: name, middleware: middleware}
+}
+
+// Use adds middleware to the end of the server middleware stack, the middleware runs in the
+// order it was added after the default middleware. The middleware can be a standard
+// func(http.Handler) http.Handler, a negroni.Handler or a negroni.HandlerFunc, or a named func
+// type of either signature. Middleware added while the server is running is used from the
+// next time it is started.
+func (s *Server) Use(middleware ...interface{}) {
+	for _, m := range middleware {
+		s.use(middlewareName(m), m)
+	}
+}
+
+// Middleware returns the names of the middleware in the order they handle requests. Standard
+// and negroni func middleware are named after the func and middleware handlers after their type.
+func (s *Server) Middleware() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, len(s.middleware))
+	for i, m := range s.middleware {
+		names[i] = m.name
+	}
+
+	return names
+}
+
+// use adds the middleware to the stack with the name given
+func (s *Server) use(name string, m interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.middleware = append(s.middleware, newMiddleware(name, m))
+}
+
+var (
+	// standardMiddlewareType is the type of a standard middleware func
+	standardMiddlewareType = reflect.TypeOf((func(http.Handler) http.Handler)(nil))
+	// negroniMiddlewareType is the type of a negroni middleware func
+	negroniMiddlewareType = reflect.TypeOf(negroni.HandlerFunc(nil))
+)
+
+// newMiddleware adapts a standard or negroni middleware to an entry of a middleware stack.
+// Funcs of a named type, such as mux.MiddlewareFunc, are converted to the middleware func
+// of the same signature.
+func newMiddleware(name string, m interface{}) middleware {
+	switch m := m.(type) {
+	case namedMiddleware:
+		return newMiddleware(m.name, m.middleware)
+	case func(http.Handler) http.Handler:
+		return middleware{name: name, wrap: m}
+	case negroni.Handler:
+		return middleware{name: name, wrap: negroniMiddleware(m)}
+	case func(http.ResponseWriter, *http.Request, http.HandlerFunc):
+		return middleware{name: name, wrap: negroniMiddleware(negroni.HandlerFunc(m))}
+	}
+
+	if v := reflect.ValueOf(m); v.Kind() == reflect.Func {
+		switch {
+		case v.Type().ConvertibleTo(standardMiddlewareType):
+			return middleware{name: name, wrap: v.Convert(standardMiddlewareType).Interface().(func(http.Handler) http.Handler)}
+		case v.Type().ConvertibleTo(negroniMiddlewareType):
+			return middleware{name: name, wrap: negroniMiddleware(v.Convert(negroniMiddlewareType).Interface().(negroni.HandlerFunc))}
+		}
+	}
+
+	panic(fmt.Sprintf("common/server: unsupported middleware type %T", m))
+}
+
+// useDefault adds the default middleware to the stack, the tracing middleware is
+// only added when a span exporter is set
+func (s *Server) useDefault(name DefaultMiddleware) {
+	switch name {
+	case MiddlewareRecovery:
+		s.use(string(name), s.recovery)
+	case MiddlewareRequestID:
+		s.use(string(name), requestID)
+	case MiddlewareTracing:
+		if s.tracer.exporter != nil {
+			s.tracer.logger = s.logger
+			s.use(string(name), &s.tracer)
+		}
+	case MiddlewareAccessLog:
+		s.use(string(name), &s.accessLog)
+	case MiddlewareRequestLogger:
+		s.use(string(name), s.requestLogger)
+	case MiddlewareStatic:
+		s.use(string(name), negroni.NewStatic(http.Dir("public")))
+	default:
+		panic("common/server: unknown default middleware " + string(name))
+	}
+}
+
+// handler builds the middleware stack around the router. The response writer is wrapped
+// once so the middleware and the routes share the status and size written.
+func (s *Server) handler() http.Handler {
+	var next http.Handler = s.Router
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		next = s.middleware[i].wrap(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(negroni.NewResponseWriter(w), r)
+	})
+}
+
+// negroniMiddleware adapts the negroni handler to a standard middleware
+func negroniMiddleware(h negroni.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r, next.ServeHTTP)
+		})
+	}
+}
+
+// middlewareName returns the name of the func or the type of the middleware handler
+func middlewareName(m interface{}) string {
+	if named, ok := m.(namedMiddleware); ok {
+		return named.name
+	}
+	if v := reflect.ValueOf(m); v.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+
+	return fmt.Sprintf("%T", m)
+}
+
+// middlewareHandler returns the handler listing the middleware of the server in order
+func (s *Server) middlewareHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	jres.Send(w, http.StatusOK, s.Middleware())
+}
@@ -0,0 +1,187 @@
+package srv_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/negroni"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_Use(t *testing.T) {
+	t.Run("Order", testServer_Use_Order)
+	t.Run("DefaultMiddleware", testServer_Use_DefaultMiddleware)
+	t.Run("Restart", testServer_Use_Restart)
+	t.Run("NamedFuncType", testServer_Use_NamedFuncType)
+	t.Run("Unsupported", testServer_Use_Unsupported)
+	t.Run("Recovery", testServer_Use_Recovery)
+	t.Run("Endpoint", testServer_Use_Endpoint)
+}
+
+// addHeader is a standard middleware adding its name to the X-Middleware response header
+func addHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Middleware", "standard")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addNegroniHeader is a negroni middleware adding its name to the X-Middleware response header
+func addNegroniHeader(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	w.Header().Add("X-Middleware", "negroni")
+	next(w, r)
+}
+
+// middlewareFunc is a named standard middleware type like mux.MiddlewareFunc
+type middlewareFunc func(http.Handler) http.Handler
+
+// negroniFunc is a named negroni middleware type
+type negroniFunc func(http.ResponseWriter, *http.Request, http.HandlerFunc)
+
+func testServer_Use_Order(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Use(addNegroniHeader, addHeader)
+	s.Use(negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		w.Header().Add("X-Middleware", "handler")
+		next(w, r)
+	}))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"negroni", "standard", "handler"}, res.Header.Values("X-Middleware"))
+	assert.Equal([]string{
+		"requestId", "recovery", "accessLog", "requestLogger",
+		"github.com/go-nm/srv_test.addNegroniHeader",
+		"github.com/go-nm/srv_test.addHeader",
+		"github.com/go-nm/srv_test.testServer_Use_Order.func2",
+	}, s.Middleware())
+}
+
+func testServer_Use_DefaultMiddleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionDefaultMiddleware(srv.MiddlewareRecovery, srv.MiddlewareStatic))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Empty(res.Header.Get(srv.RequestIDHeader))
+	assert.Equal([]string{"recovery", "static"}, s.Middleware())
+}
+
+func testServer_Use_Restart(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	calls := 0
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		calls++
+	})
+	s.Start("127.0.0.1:0")
+	s.Shutdown()
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	_, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(1, calls)
+}
+
+func testServer_Use_NamedFuncType(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Use(negroniFunc(addNegroniHeader), middlewareFunc(addHeader))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"negroni", "standard"}, res.Header.Values("X-Middleware"))
+	assert.Equal([]string{
+		"requestId", "recovery", "accessLog", "requestLogger",
+		"github.com/go-nm/srv_test.addNegroniHeader",
+		"github.com/go-nm/srv_test.addHeader",
+	}, s.Middleware())
+}
+
+func testServer_Use_Unsupported(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act / Assert
+	assert.PanicsWithValue("common/server: unsupported middleware type string", func() { s.Use("middleware") })
+}
+
+func testServer_Use_Recovery(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewTextHandler(ioutil.Discard, nil))))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("failed")
+		})
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+	body, _ := ioutil.ReadAll(res.Body)
+	var data map[string]interface{}
+	jsonErr := json.Unmarshal(body, &data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.NoError(jsonErr)
+	assert.Equal("internal server error", data["message"])
+	assert.NotContains(string(body), "goroutine")
+}
+
+func testServer_Use_Endpoint(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionAppEnv("dev"))
+	s.Use(addHeader)
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/_system/middleware")
+	var data []string
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"requestId", "recovery", "accessLog", "requestLogger", "github.com/go-nm/srv_test.addHeader"}, data)
+}
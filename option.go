@@ -1,10 +1,53 @@
 package srv
 
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
 type optionName int
 
 const (
 	optionContextPath optionName = iota
 	optionAppEnv
+	optionTLSCertFiles
+	optionTLSConfig
+	optionTLSClientCAFile
+	optionTLSClientAuth
+	optionTLSReloadInterval
+	optionTLSExpiryThreshold
+	optionSocketActivation
+	optionUnixSocketMode
+	optionShutdownTimeout
+	optionStopSignals
+	optionDrainDelay
+	optionUpgradeSignal
+	optionAdminAddr
+	optionAdminListener
+	optionReadTimeout
+	optionReadHeaderTimeout
+	optionWriteTimeout
+	optionIdleTimeout
+	optionMaxHeaderBytes
+	optionErrorLog
+	optionDisableKeepAlivesOnDrain
+	optionHealthCheckTimeout
+	optionHealthContentType
+	optionHealthHistorySize
+	optionRequestDurationBuckets
+	optionTracing
+	optionDisableRequestID
+	optionLogger
+	optionAccessLogFormat
+	optionAccessLogOutput
+	optionAccessLogSampleRate
+	optionAccessLogExclude
+	optionDefaultMiddleware
 )
 
 // Option is the struct for server based options
@@ -22,9 +65,237 @@ func OptionContextPath(path string) Option {
 }
 
 // OptionAppEnv is used to set specific security runtime environment variables.
-// When value is dev the panic handler is disabled to view stacktraces in the browser
-// and the /_system/routes route is avaliable to show all routes that were registered
-// with the server.
+// When value is dev or test the /_system/routes route is avaliable to show all routes
+// that were registered with the server.
 func OptionAppEnv(envName string) Option {
 	return Option{name: optionAppEnv, value: envName}
 }
+
+// OptionTLSCertFiles is used to set the PEM encoded certificate and private key files
+// used by RunTLS. If the certificate is signed by a certificate authority the certFile
+// should be the concatenation of the server's certificate, any intermediates, and the CA's certificate.
+func OptionTLSCertFiles(certFile, keyFile string) Option {
+	return Option{name: optionTLSCertFiles, value: [2]string{certFile, keyFile}}
+}
+
+// OptionTLSConfig is used to provide an in-memory TLS configuration for RunTLS.
+// The config is cloned before use. When OptionTLSCertFiles is also set the certificate
+// files are served to the clients they suit and the GetCertificate and Certificates of
+// the config are used for the other clients, such as those asking for another server name.
+func OptionTLSConfig(config *tls.Config) Option {
+	return Option{name: optionTLSConfig, value: config}
+}
+
+// OptionTLSClientCAFile is used to enable mutual TLS. The PEM encoded certificate
+// authorities in caFile are used to verify client certificates and every client
+// is required to present a valid certificate unless OptionTLSClientAuth is also set.
+func OptionTLSClientCAFile(caFile string) Option {
+	return Option{name: optionTLSClientCAFile, value: caFile}
+}
+
+// OptionTLSClientAuth is used to set the policy the server will follow for
+// TLS client authentication, such as tls.VerifyClientCertIfGiven.
+func OptionTLSClientAuth(authType tls.ClientAuthType) Option {
+	return Option{name: optionTLSClientAuth, value: authType}
+}
+
+// OptionTLSReloadInterval is used to set how often the files set by OptionTLSCertFiles
+// are checked for changes. When the files change the certificate is swapped without
+// restarting the server. The default is one minute, zero disables watching the files.
+func OptionTLSReloadInterval(interval time.Duration) Option {
+	return Option{name: optionTLSReloadInterval, value: interval}
+}
+
+// OptionTLSExpiryThreshold is used to set how close to expiring the served certificate
+// can get before the tlsCertificate readiness check fails. The default is 24 hours.
+func OptionTLSExpiryThreshold(threshold time.Duration) Option {
+	return Option{name: optionTLSExpiryThreshold, value: threshold}
+}
+
+// OptionSocketActivation is used to serve on the socket passed to the process by
+// systemd socket activation (LISTEN_FDS) instead of binding the addr given to Run.
+// Run returns ErrNoSocketActivation if the process was not socket activated.
+func OptionSocketActivation() Option {
+	return Option{name: optionSocketActivation, value: true}
+}
+
+// OptionUnixSocketMode is used to set the file permissions of the unix domain socket
+// created when Run is called with a "unix:" prefixed address, such as 0660 to allow
+// a reverse proxy in the same group to connect.
+func OptionUnixSocketMode(mode os.FileMode) Option {
+	return Option{name: optionUnixSocketMode, value: mode}
+}
+
+// OptionShutdownTimeout is used to set the amount of time to wait for HTTP requests and
+// shutdown hooks to complete before forcing the server to shut down. This should be less
+// than the terminationGracePeriodSeconds when running in Kubernetes. The default is 30 seconds.
+func OptionShutdownTimeout(timeout time.Duration) Option {
+	return Option{name: optionShutdownTimeout, value: timeout}
+}
+
+// OptionStopSignals is used to set the OS signals that gracefully shut down the server
+// when it was started with Run, RunTLS, Serve or ServeTLS.
+// The default is SIGHUP, SIGINT, SIGQUIT and SIGTERM. Passing no signals turns off the
+// signal handling so the server runs until Shutdown is called or the context of RunContext ends.
+func OptionStopSignals(signals ...os.Signal) Option {
+	return Option{name: optionStopSignals, value: signals}
+}
+
+// OptionDrainDelay is used to set how long the server keeps serving requests after
+// receiving a stop signal, or having the RunContext context cancelled, before the
+// graceful shutdown begins. During the delay /_system/readiness reports "draining" so
+// load balancers stop routing new requests while liveness stays healthy.
+// The default is zero which starts the shutdown immediately.
+func OptionDrainDelay(delay time.Duration) Option {
+	return Option{name: optionDrainDelay, value: delay}
+}
+
+// OptionUpgradeSignal is used to enable zero-downtime binary upgrades when the OS signal,
+// such as SIGUSR2, is received by Run. The new executable is started with the listening
+// socket and this process is gracefully shut down once the new process is serving.
+// The signal must not also be one of the stop signals.
+func OptionUpgradeSignal(sig os.Signal) Option {
+	return Option{name: optionUpgradeSignal, value: sig}
+}
+
+// OptionAdminAddr is used to serve the /_system endpoints on a separate admin address,
+// such as "127.0.0.1:9090", instead of the public listener. The admin endpoints are not
+// prefixed with the context path and are started and shut down together with the server.
+func OptionAdminAddr(addr string) Option {
+	return Option{name: optionAdminAddr, value: addr}
+}
+
+// OptionAdminListener is used to serve the /_system endpoints on the listener provided
+// instead of the public listener. The listener is closed when the server is shut down.
+func OptionAdminListener(l net.Listener) Option {
+	return Option{name: optionAdminListener, value: l}
+}
+
+// OptionReadTimeout is used to set the maximum duration for reading an entire
+// request, including the body. The default is 30 seconds, zero means no timeout.
+func OptionReadTimeout(timeout time.Duration) Option {
+	return Option{name: optionReadTimeout, value: timeout}
+}
+
+// OptionReadHeaderTimeout is used to set the amount of time allowed to read the request
+// headers, protecting against slowloris-style clients. The default is 10 seconds.
+func OptionReadHeaderTimeout(timeout time.Duration) Option {
+	return Option{name: optionReadHeaderTimeout, value: timeout}
+}
+
+// OptionWriteTimeout is used to set the maximum duration before timing out writes of
+// the response. The default is 30 seconds, zero means no timeout which is required for
+// long lived streaming responses.
+func OptionWriteTimeout(timeout time.Duration) Option {
+	return Option{name: optionWriteTimeout, value: timeout}
+}
+
+// OptionIdleTimeout is used to set the maximum amount of time to wait for the next
+// request when keep-alives are enabled. The default is 120 seconds.
+func OptionIdleTimeout(timeout time.Duration) Option {
+	return Option{name: optionIdleTimeout, value: timeout}
+}
+
+// OptionMaxHeaderBytes is used to set the maximum number of bytes the server will
+// read parsing the request headers. The default is 1MB.
+func OptionMaxHeaderBytes(size int) Option {
+	return Option{name: optionMaxHeaderBytes, value: size}
+}
+
+// OptionErrorLog is used to set the logger for errors accepting connections,
+// unexpected behavior from handlers and underlying file system errors.
+// The default logs to the OptionLogger logger at the error level when it is set
+// and otherwise uses the log package's standard logger.
+func OptionErrorLog(logger *log.Logger) Option {
+	return Option{name: optionErrorLog, value: logger}
+}
+
+// OptionDisableKeepAlivesOnDrain is used to close idle keep-alive connections and stop
+// reusing connections when the server starts draining, which moves clients to other
+// instances sooner. See OptionDrainDelay.
+func OptionDisableKeepAlivesOnDrain(disable bool) Option {
+	return Option{name: optionDisableKeepAlivesOnDrain, value: disable}
+}
+
+// OptionHealthCheckTimeout is used to set the default amount of time each readiness and
+// liveness check can run before it is reported with a "timeout" status. Checks can set
+// their own timeout with CheckTimeout. The default is 5 seconds.
+func OptionHealthCheckTimeout(timeout time.Duration) Option {
+	return Option{name: optionHealthCheckTimeout, value: timeout}
+}
+
+// OptionHealthContentType is used to set the format of the health endpoints when the request
+// does not ask for one in its Accept header. Use HealthJSONContentType for the IETF
+// application/health+json format. The default is "application/json" for HealthResponse.
+func OptionHealthContentType(contentType string) Option {
+	return Option{name: optionHealthContentType, value: contentType}
+}
+
+// OptionHealthHistorySize is used to set the number of health check changes kept for the
+// /_system/health/history endpoint, the oldest changes are dropped first. The default is 100.
+func OptionHealthHistorySize(size int) Option {
+	return Option{name: optionHealthHistorySize, value: size}
+}
+
+// OptionRequestDurationBuckets is used to set the upper bounds in seconds of the buckets of
+// the request latency histograms exported by the /_system/metrics endpoint. The default buckets
+// range from 5 milliseconds to 10 seconds.
+func OptionRequestDurationBuckets(buckets ...float64) Option {
+	return Option{name: optionRequestDurationBuckets, value: buckets}
+}
+
+// OptionTracing is used to record a server span for each request and export it with the exporter
+// provided, such as an OTLPExporter. Requests join the trace of a valid W3C traceparent header and
+// the spans are named after the route template. Tracing is disabled by default.
+func OptionTracing(exporter SpanExporter) Option {
+	return Option{name: optionTracing, value: exporter}
+}
+
+// OptionDisableRequestID is used to turn off the request id middleware. The middleware is on by
+// default and adds the X-Request-ID of the request, or a generated id, to the request context
+// and the response. Use RequestIDFromContext to read it.
+func OptionDisableRequestID(disable bool) Option {
+	return Option{name: optionDisableRequestID, value: disable}
+}
+
+// OptionLogger is used to set the structured logger for the server lifecycle events, panics and
+// access logs. Request handlers get a logger carrying the request id, trace id and route with
+// LoggerFromContext. The default is slog.Default().
+func OptionLogger(logger *slog.Logger) Option {
+	return Option{name: optionLogger, value: logger}
+}
+
+// OptionAccessLogFormat is used to set the format of the access log. AccessLogStructured logs each
+// request through the server logger and the Apache common and combined, JSON and logfmt formats
+// write a line per request to the access log output. The default is AccessLogStructured.
+func OptionAccessLogFormat(format AccessLogFormat) Option {
+	return Option{name: optionAccessLogFormat, value: format}
+}
+
+// OptionAccessLogOutput is used to set where the access log lines are written for the formats
+// other than AccessLogStructured. The default is os.Stdout.
+func OptionAccessLogOutput(w io.Writer) Option {
+	return Option{name: optionAccessLogOutput, value: w}
+}
+
+// OptionAccessLogSampleRate is used to log only a fraction of the requests, between 0 and 1.
+// Requests that return a server error are always logged. The default is 1 to log every request.
+func OptionAccessLogSampleRate(rate float64) Option {
+	return Option{name: optionAccessLogSampleRate, value: rate}
+}
+
+// OptionAccessLogExclude is used to stop logging requests to the paths given, such as the
+// "/_system/liveness" probe. A path ending in * excludes all the paths it prefixes. Paths are
+// matched against the full request path, so with OptionContextPath("/api") the probe is
+// excluded with "/api/_system/liveness".
+func OptionAccessLogExclude(paths ...string) Option {
+	return Option{name: optionAccessLogExclude, value: paths}
+}
+
+// OptionDefaultMiddleware is used to choose the middleware the server installs, in the order
+// given, ahead of the middleware added with Use. The default is MiddlewareRecovery,
+// MiddlewareRequestID, MiddlewareTracing, MiddlewareAccessLog and MiddlewareRequestLogger.
+// Add MiddlewareStatic to serve the files of the "public" directory.
+func OptionDefaultMiddleware(middleware ...DefaultMiddleware) Option {
+	return Option{name: optionDefaultMiddleware, value: middleware}
+}
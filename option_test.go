@@ -1,7 +1,17 @@
 package srv
 
 import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -31,3 +41,481 @@ func TestOptionEnvName(t *testing.T) {
 	assert.Equal(got.name, optionAppEnv)
 	assert.Equal(got.value, envName)
 }
+
+func TestOptionTLSCertFiles(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionTLSCertFiles("server.crt", "server.key")
+
+	// Assert
+	assert.Equal(got.name, optionTLSCertFiles)
+	assert.Equal(got.value, [2]string{"server.crt", "server.key"})
+}
+
+func TestOptionTLSConfig(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	config := &tls.Config{}
+
+	// Act
+	got := OptionTLSConfig(config)
+
+	// Assert
+	assert.Equal(got.name, optionTLSConfig)
+	assert.Equal(got.value, config)
+}
+
+func TestOptionTLSClientCAFile(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionTLSClientCAFile("ca.crt")
+
+	// Assert
+	assert.Equal(got.name, optionTLSClientCAFile)
+	assert.Equal(got.value, "ca.crt")
+}
+
+func TestOptionTLSClientAuth(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionTLSClientAuth(tls.VerifyClientCertIfGiven)
+
+	// Assert
+	assert.Equal(got.name, optionTLSClientAuth)
+	assert.Equal(got.value, tls.VerifyClientCertIfGiven)
+}
+
+func TestOptionTLSReloadInterval(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionTLSReloadInterval(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionTLSReloadInterval)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestOptionTLSExpiryThreshold(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionTLSExpiryThreshold(time.Hour)
+
+	// Assert
+	assert.Equal(got.name, optionTLSExpiryThreshold)
+	assert.Equal(got.value, time.Hour)
+}
+
+func TestOptionSocketActivation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionSocketActivation()
+
+	// Assert
+	assert.Equal(got.name, optionSocketActivation)
+	assert.Equal(got.value, true)
+}
+
+func TestOptionUnixSocketMode(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionUnixSocketMode(0660)
+
+	// Assert
+	assert.Equal(got.name, optionUnixSocketMode)
+	assert.Equal(got.value, os.FileMode(0660))
+}
+
+func TestOptionShutdownTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionShutdownTimeout(time.Minute)
+
+	// Assert
+	assert.Equal(got.name, optionShutdownTimeout)
+	assert.Equal(got.value, time.Minute)
+}
+
+func TestOptionStopSignals(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionStopSignals(syscall.SIGTERM)
+
+	// Assert
+	assert.Equal(got.name, optionStopSignals)
+	assert.Equal(got.value, []os.Signal{syscall.SIGTERM})
+}
+
+func TestOptionDrainDelay(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionDrainDelay(5 * time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionDrainDelay)
+	assert.Equal(got.value, 5*time.Second)
+}
+
+func TestOptionAdminAddr(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionAdminAddr("127.0.0.1:9090")
+
+	// Assert
+	assert.Equal(got.name, optionAdminAddr)
+	assert.Equal(got.value, "127.0.0.1:9090")
+}
+
+func TestOptionAdminListener(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	// Act
+	got := OptionAdminListener(l)
+
+	// Assert
+	assert.Equal(got.name, optionAdminListener)
+	assert.Equal(got.value, l)
+}
+
+func TestOptionReadTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionReadTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionReadTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestOptionReadHeaderTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionReadHeaderTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionReadHeaderTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestOptionWriteTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionWriteTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionWriteTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestOptionIdleTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionIdleTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionIdleTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestOptionMaxHeaderBytes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionMaxHeaderBytes(4096)
+
+	// Assert
+	assert.Equal(got.name, optionMaxHeaderBytes)
+	assert.Equal(got.value, 4096)
+}
+
+func TestOptionDisableKeepAlivesOnDrain(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionDisableKeepAlivesOnDrain(true)
+
+	// Assert
+	assert.Equal(got.name, optionDisableKeepAlivesOnDrain)
+	assert.Equal(got.value, true)
+}
+
+func TestOptionErrorLog(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logger := log.New(ioutil.Discard, "", 0)
+
+	// Act
+	got := OptionErrorLog(logger)
+
+	// Assert
+	assert.Equal(got.name, optionErrorLog)
+	assert.Equal(got.value, logger)
+}
+
+func TestOptionHealthCheckTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionHealthCheckTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, optionHealthCheckTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := CheckTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, healthCheckOptionTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestCheckCacheTTL(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := CheckCacheTTL(time.Minute)
+
+	// Assert
+	assert.Equal(got.name, healthCheckOptionCacheTTL)
+	assert.Equal(got.value, time.Minute)
+}
+
+func TestCheckInterval(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := CheckInterval(time.Minute)
+
+	// Assert
+	assert.Equal(got.name, healthCheckOptionInterval)
+	assert.Equal(got.value, time.Minute)
+}
+
+func TestCheckCritical(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := CheckCritical(false)
+
+	// Assert
+	assert.Equal(got.name, healthCheckOptionCritical)
+	assert.Equal(got.value, false)
+}
+
+func TestOptionHealthContentType(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionHealthContentType(HealthJSONContentType)
+
+	// Assert
+	assert.Equal(got.name, optionHealthContentType)
+	assert.Equal(got.value, HealthJSONContentType)
+}
+
+func TestOptionHealthHistorySize(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionHealthHistorySize(10)
+
+	// Assert
+	assert.Equal(got.name, optionHealthHistorySize)
+	assert.Equal(got.value, 10)
+}
+
+func TestOptionRequestDurationBuckets(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionRequestDurationBuckets(0.1, 1)
+
+	// Assert
+	assert.Equal(got.name, optionRequestDurationBuckets)
+	assert.Equal(got.value, []float64{0.1, 1})
+}
+
+func TestOptionTracing(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := NewInMemoryExporter()
+
+	// Act
+	got := OptionTracing(exporter)
+
+	// Assert
+	assert.Equal(got.name, optionTracing)
+	assert.Equal(got.value, exporter)
+}
+
+func TestOptionDisableRequestID(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionDisableRequestID(true)
+
+	// Assert
+	assert.Equal(got.name, optionDisableRequestID)
+	assert.Equal(got.value, true)
+}
+
+func TestOptionLogger(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	logger := slog.New(slog.NewTextHandler(ioutil.Discard, nil))
+
+	// Act
+	got := OptionLogger(logger)
+
+	// Assert
+	assert.Equal(got.name, optionLogger)
+	assert.Equal(got.value, logger)
+}
+
+func TestOptionAccessLogFormat(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionAccessLogFormat(AccessLogCombined)
+
+	// Assert
+	assert.Equal(got.name, optionAccessLogFormat)
+	assert.Equal(got.value, AccessLogCombined)
+}
+
+func TestOptionAccessLogOutput(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	out := &bytes.Buffer{}
+
+	// Act
+	got := OptionAccessLogOutput(out)
+
+	// Assert
+	assert.Equal(got.name, optionAccessLogOutput)
+	assert.Equal(got.value, out)
+}
+
+func TestOptionAccessLogSampleRate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionAccessLogSampleRate(0.25)
+
+	// Assert
+	assert.Equal(got.name, optionAccessLogSampleRate)
+	assert.Equal(got.value, 0.25)
+}
+
+func TestOptionAccessLogExclude(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionAccessLogExclude("/_system/liveness", "/static/*")
+
+	// Assert
+	assert.Equal(got.name, optionAccessLogExclude)
+	assert.Equal(got.value, []string{"/_system/liveness", "/static/*"})
+}
+
+func TestOptionDefaultMiddleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionDefaultMiddleware(MiddlewareRecovery, MiddlewareStatic)
+
+	// Assert
+	assert.Equal(got.name, optionDefaultMiddleware)
+	assert.Equal(got.value, []DefaultMiddleware{MiddlewareRecovery, MiddlewareStatic})
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	auth := func(next http.Handler) http.Handler { return next }
+
+	// Act
+	got := RouteMiddleware(NamedMiddleware("auth", auth))
+
+	// Assert
+	assert.Equal(got.name, routeOptionMiddleware)
+	if entries, ok := got.value.([]middleware); assert.True(ok) && assert.Len(entries, 1) {
+		assert.Equal("auth", entries[0].name)
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := RouteTimeout(time.Second)
+
+	// Assert
+	assert.Equal(got.name, routeOptionTimeout)
+	assert.Equal(got.value, time.Second)
+}
+
+func TestRouteMaxBodyBytes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := RouteMaxBodyBytes(1024)
+
+	// Assert
+	assert.Equal(got.name, routeOptionMaxBodyBytes)
+	assert.Equal(got.value, int64(1024))
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package srv
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionUpgradeSignal(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	got := OptionUpgradeSignal(syscall.SIGUSR2)
+
+	// Assert
+	assert.Equal(got.name, optionUpgradeSignal)
+	assert.Equal(got.value, syscall.SIGUSR2)
+}
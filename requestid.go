@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// RequestIDHeader is the header the request id is read from and returned in
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request id accepted from a client
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the request id
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the id of the request being served, it is empty
+// when the request id middleware is disabled
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestID is the middleware that adds the request id to the request context and the
+// response. The X-Request-ID header of the request is used when it is valid so the id
+// matches the one logged by a proxy or the client, otherwise a new id is generated.
+func requestID(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+
+	next(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+}
+
+// validRequestID tells if the id is short and only printable ASCII so it is safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
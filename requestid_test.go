@@ -0,0 +1,154 @@
+package srv_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_RequestID(t *testing.T) {
+	t.Run("Generated", testServer_RequestID_Generated)
+	t.Run("FromHeader", testServer_RequestID_FromHeader)
+	t.Run("InvalidHeader", testServer_RequestID_InvalidHeader)
+	t.Run("ErrorBody", testServer_RequestID_ErrorBody)
+	t.Run("PanicBody", testServer_RequestID_PanicBody)
+	t.Run("Disabled", testServer_RequestID_Disabled)
+}
+
+// getWithRequestID requests the path from the running server with the X-Request-ID header set when it is not empty
+func getWithRequestID(s *srv.Server, path, id string) (*http.Response, error) {
+	req, _ := http.NewRequest("GET", "http://"+s.Addr().String()+path, nil)
+	if id != "" {
+		req.Header.Set(srv.RequestIDHeader, id)
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+func testServer_RequestID_Generated(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	var got string
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		got = srv.RequestIDFromContext(r.Context())
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "")
+
+	// Assert
+	assert.NoError(err)
+	assert.Regexp(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got)
+	assert.Equal(got, res.Header.Get(srv.RequestIDHeader))
+}
+
+func testServer_RequestID_FromHeader(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	var got string
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		got = srv.RequestIDFromContext(r.Context())
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "upstream-id-1")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal("upstream-id-1", got)
+	assert.Equal("upstream-id-1", res.Header.Get(srv.RequestIDHeader))
+}
+
+func testServer_RequestID_InvalidHeader(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	spaces, spacesErr := getWithRequestID(s, "/test", "id with spaces")
+	long, longErr := getWithRequestID(s, "/test", strings.Repeat("a", 129))
+
+	// Assert
+	assert.NoError(spacesErr)
+	assert.NoError(longErr)
+	assert.NotEqual("id with spaces", spaces.Header.Get(srv.RequestIDHeader))
+	assert.Len(spaces.Header.Get(srv.RequestIDHeader), 36)
+	assert.Len(long.Header.Get(srv.RequestIDHeader), 36)
+}
+
+func testServer_RequestID_ErrorBody(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/missing", "missing-1")
+	var data map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+	assert.Equal(map[string]interface{}{"requestId": "missing-1"}, data["info"])
+}
+
+func testServer_RequestID_PanicBody(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionLogger(slog.New(slog.NewTextHandler(ioutil.Discard, nil))))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("failed")
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "panic-1")
+	var data map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(map[string]interface{}{"requestId": "panic-1"}, data["info"])
+}
+
+func testServer_RequestID_Disabled(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionDisableRequestID(true))
+	got := "unset"
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		got = srv.RequestIDFromContext(r.Context())
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := getWithRequestID(s, "/test", "")
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(got)
+	assert.Empty(res.Header.Get(srv.RequestIDHeader))
+}
@@ -0,0 +1,139 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/urfave/negroni"
+)
+
+type routeOptionName int
+
+const (
+	routeOptionMiddleware routeOptionName = iota
+	routeOptionTimeout
+	routeOptionMaxBodyBytes
+)
+
+// RouteOption is the struct for route based options
+type RouteOption struct {
+	name  routeOptionName
+	value interface{}
+}
+
+// RouteMiddleware is used to run middleware for the route only, after the middleware of its
+// group. The middleware can be a standard func(http.Handler) http.Handler, a negroni.Handler
+// or a negroni.HandlerFunc, use NamedMiddleware to set the name shown by the routes endpoint.
+func RouteMiddleware(handlers ...interface{}) RouteOption {
+	entries := make([]middleware, len(handlers))
+	for i, m := range handlers {
+		entries[i] = newMiddleware(middlewareName(m), m)
+	}
+
+	return RouteOption{name: routeOptionMiddleware, value: entries}
+}
+
+// RouteTimeout is used to set a deadline on the request context of the route. Handlers should
+// stop once the context is done, a 503 error is sent when the handler returns without a response.
+func RouteTimeout(timeout time.Duration) RouteOption {
+	return RouteOption{name: routeOptionTimeout, value: timeout}
+}
+
+// RouteMaxBodyBytes is used to limit the size of the request body of the route, reading past
+// the limit returns an error and closes the connection once the response is sent.
+func RouteMaxBodyBytes(n int64) RouteOption {
+	return RouteOption{name: routeOptionMaxBodyBytes, value: n}
+}
+
+// newRoute creates the route info and the middleware of the route from the group middleware
+// followed by the route options in the order given
+func newRoute(method, path string, group []middleware, opts []RouteOption) (RouteInfo, []middleware) {
+	info := RouteInfo{Method: method, Path: path}
+	chain := append([]middleware(nil), group...)
+
+	for _, o := range opts {
+		switch o.name {
+		case routeOptionMiddleware:
+			chain = append(chain, o.value.([]middleware)...)
+		case routeOptionTimeout:
+			timeout := o.value.(time.Duration)
+			chain = append(chain, middleware{name: "timeout", wrap: timeoutMiddleware(timeout)})
+			info.setOption("timeout", timeout.String())
+		case routeOptionMaxBodyBytes:
+			n := o.value.(int64)
+			chain = append(chain, middleware{name: "maxBodyBytes", wrap: maxBodyBytesMiddleware(n)})
+			info.setOption("maxBodyBytes", n)
+		}
+	}
+
+	for _, m := range chain {
+		info.Middleware = append(info.Middleware, m.name)
+	}
+
+	return info, chain
+}
+
+// setOption records the value of a route option applied to the route
+func (i *RouteInfo) setOption(name string, value interface{}) {
+	if i.Options == nil {
+		i.Options = map[string]interface{}{}
+	}
+
+	i.Options[name] = value
+}
+
+// timeoutMiddleware sets the deadline on the request context and sends a 503 error
+// when the deadline passed before the handler wrote a response
+func timeoutMiddleware(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			rw, ok := w.(negroni.ResponseWriter)
+			if !ok {
+				rw = negroni.NewResponseWriter(w)
+			}
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			if ctx.Err() == context.DeadlineExceeded && !rw.Written() {
+				sendError(rw, r, http.StatusServiceUnavailable, "request timed out", nil)
+			}
+		})
+	}
+}
+
+// maxBodyBytesMiddleware limits the request body to n bytes
+func maxBodyBytesMiddleware(n int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// chainHandle wraps the handle in the middleware, the route params are passed through
+// the request context so they reach the handle after the standard middleware
+func chainHandle(middleware []middleware, handle httprouter.Handle) httprouter.Handle {
+	if len(middleware) == 0 {
+		return handle
+	}
+
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, httprouter.ParamsFromContext(r.Context()))
+	})
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i].wrap(next)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, ps)))
+	}
+}
@@ -0,0 +1,116 @@
+package srv_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_RouteOptions(t *testing.T) {
+	t.Run("Middleware", testServer_RouteOptions_Middleware)
+	t.Run("RouteInfo", testServer_RouteOptions_RouteInfo)
+	t.Run("Timeout", testServer_RouteOptions_Timeout)
+	t.Run("MaxBodyBytes", testServer_RouteOptions_MaxBodyBytes)
+}
+
+func testServer_RouteOptions_Middleware(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	handle := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {}
+	s.Group("/admin", tagMiddleware("group")).GET("/users", handle, srv.RouteMiddleware(tagMiddleware("route")))
+	s.GET("/users", handle)
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	admin, adminErr := http.Get("http://" + s.Addr().String() + "/admin/users")
+	public, publicErr := http.Get("http://" + s.Addr().String() + "/users")
+
+	// Assert
+	assert.NoError(adminErr)
+	assert.NoError(publicErr)
+	assert.Equal([]string{"group", "route"}, admin.Header.Values("X-Middleware"))
+	assert.Empty(public.Header.Values("X-Middleware"))
+}
+
+func testServer_RouteOptions_RouteInfo(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionAppEnv("dev"))
+	admin := s.Group("/admin", srv.NamedMiddleware("auth:admin", tagMiddleware("auth")))
+	admin.POST("/users", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {},
+		srv.RouteTimeout(5*time.Second), srv.RouteMaxBodyBytes(1024))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/_system/routes")
+	var routes []srv.RouteInfo
+	decodeJres(res.StatusCode, res.Body, &routes)
+
+	// Assert
+	assert.NoError(err)
+	assert.Contains(routes, srv.RouteInfo{
+		Method:     "POST",
+		Path:       "/admin/users",
+		Middleware: []string{"auth:admin", "timeout", "maxBodyBytes"},
+		Options:    map[string]interface{}{"timeout": "5s", "maxBodyBytes": float64(1024)},
+	})
+	assert.Contains(routes, srv.RouteInfo{Method: "GET", Path: "/_system/routes"})
+}
+
+func testServer_RouteOptions_Timeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/slow", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		<-r.Context().Done()
+	}, srv.RouteTimeout(10*time.Millisecond))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/slow")
+	var data map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal("request timed out", data["message"])
+}
+
+func testServer_RouteOptions_MaxBodyBytes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	var readErr error
+	s.POST("/upload", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		_, readErr = ioutil.ReadAll(r.Body)
+		if readErr != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}, srv.RouteMaxBodyBytes(4))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	small, smallErr := http.Post("http://"+s.Addr().String()+"/upload", "text/plain", strings.NewReader("tiny"))
+	large, largeErr := http.Post("http://"+s.Addr().String()+"/upload", "text/plain", strings.NewReader("too large"))
+
+	// Assert
+	assert.NoError(smallErr)
+	assert.NoError(largeErr)
+	assert.Equal(http.StatusOK, small.StatusCode)
+	assert.Equal(http.StatusRequestEntityTooLarge, large.StatusCode)
+	assert.Error(readErr)
+}
@@ -0,0 +1,219 @@
+package srv
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/urfave/negroni"
+)
+
+// defaultRequestDurationBuckets are the upper bounds in seconds of the request latency histogram buckets
+var defaultRequestDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// RouteMetrics is the number of requests served and the latency of a single route
+type RouteMetrics struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Requests uint64            `json:"requests"`
+	Errors   uint64            `json:"errors"`   // the number of requests that returned a 5xx status
+	Statuses map[string]uint64 `json:"statuses"` // the number of requests by status class such as "2xx"
+	Latency  string            `json:"latency"`  // the mean time taken to serve a request
+	MaxTime  string            `json:"maxTime"`  // the longest time taken to serve a request
+}
+
+// routeKey identifies a route by its method and registered path
+type routeKey struct {
+	method string
+	route  string
+}
+
+// routeStats is the number of requests a route served by status code and the
+// latency of the requests by status class
+type routeStats struct {
+	codes     map[int]uint64
+	durations map[string]*durationHistogram
+	total     time.Duration
+	max       time.Duration
+}
+
+// durationHistogram counts the request durations that fall in each bucket
+type durationHistogram struct {
+	counts []uint64 // the count of each bucket followed by the count above the last bucket
+	sum    float64
+	count  uint64
+}
+
+// requestMetrics records the rate, errors and duration of the requests served by each
+// route registered on the server
+type requestMetrics struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	routes map[routeKey]*routeStats
+}
+
+// instrument wraps the handle to record its requests by the status code returned. A handle
+// that panics is recorded as a 500 before the panic is passed on to the panic handler.
+func (m *requestMetrics) instrument(method, route string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		rw, ok := w.(negroni.ResponseWriter)
+		if !ok {
+			rw = negroni.NewResponseWriter(w)
+		}
+
+		defer func() {
+			if err := recover(); err != nil {
+				m.observe(method, route, http.StatusInternalServerError, time.Since(start))
+				panic(err)
+			}
+
+			status := rw.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.observe(method, route, status, time.Since(start))
+		}()
+
+		handle(rw, r, ps)
+	}
+}
+
+// observe records a request served by the route
+func (m *requestMetrics) observe(method, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.routes == nil {
+		m.routes = map[routeKey]*routeStats{}
+	}
+	if m.buckets == nil {
+		m.buckets = defaultRequestDurationBuckets
+	}
+
+	key := routeKey{method: method, route: route}
+	stats, ok := m.routes[key]
+	if !ok {
+		stats = &routeStats{codes: map[int]uint64{}, durations: map[string]*durationHistogram{}}
+		m.routes[key] = stats
+	}
+
+	stats.codes[status]++
+	stats.total += duration
+	if duration > stats.max {
+		stats.max = duration
+	}
+
+	class := statusClass(status)
+	hist, ok := stats.durations[class]
+	if !ok {
+		hist = &durationHistogram{counts: make([]uint64, len(m.buckets)+1)}
+		stats.durations[class] = hist
+	}
+
+	seconds := duration.Seconds()
+	hist.counts[sort.SearchFloat64s(m.buckets, seconds)]++
+	hist.sum += seconds
+	hist.count++
+}
+
+// statusClass returns the class of the status code such as "2xx"
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// sortedKeys returns the routes that have served requests ordered by path and method,
+// the caller must hold the lock
+func (m *requestMetrics) sortedKeys() []routeKey {
+	keys := make([]routeKey, 0, len(m.routes))
+	for key := range m.routes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	return keys
+}
+
+// list returns the metrics of the routes that have served requests
+func (m *requestMetrics) list() []RouteMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	routes := []RouteMetrics{}
+	for _, key := range m.sortedKeys() {
+		stats := m.routes[key]
+		route := RouteMetrics{Method: key.method, Path: key.route, Statuses: map[string]uint64{}, MaxTime: stats.max.String()}
+
+		for code, count := range stats.codes {
+			route.Requests += count
+			route.Statuses[statusClass(code)] += count
+			if code >= http.StatusInternalServerError {
+				route.Errors += count
+			}
+		}
+		route.Latency = (stats.total / time.Duration(route.Requests)).String()
+
+		routes = append(routes, route)
+	}
+
+	return routes
+}
+
+// write the request counters and latency histograms of the routes that have served requests
+func (m *requestMetrics) write(w *metricsWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.routes) == 0 {
+		return
+	}
+
+	keys := m.sortedKeys()
+
+	w.counter("srv_http_requests", "Number of HTTP requests served by route and status code.")
+	for _, key := range keys {
+		codes := make([]int, 0, len(m.routes[key].codes))
+		for code := range m.routes[key].codes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		for _, code := range codes {
+			w.sample("srv_http_requests_total", float64(m.routes[key].codes[code]),
+				"method", key.method, "route", key.route, "code", strconv.Itoa(code))
+		}
+	}
+
+	w.histogram("srv_http_request_duration_seconds", "Time taken to serve HTTP requests by route and status class.")
+	for _, key := range keys {
+		classes := make([]string, 0, len(m.routes[key].durations))
+		for class := range m.routes[key].durations {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+
+		for _, class := range classes {
+			hist := m.routes[key].durations[class]
+			labels := []string{"method", key.method, "route", key.route, "class", class}
+
+			var cumulative uint64
+			for i, bucket := range m.buckets {
+				cumulative += hist.counts[i]
+				w.sample("srv_http_request_duration_seconds_bucket", float64(cumulative), append(labels, "le", formatMetricValue(bucket))...)
+			}
+			w.sample("srv_http_request_duration_seconds_bucket", float64(hist.count), append(labels, "le", "+Inf")...)
+			w.sample("srv_http_request_duration_seconds_sum", hist.sum, labels...)
+			w.sample("srv_http_request_duration_seconds_count", float64(hist.count), labels...)
+		}
+	}
+}
@@ -2,24 +2,40 @@ package srv
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
-	"github.com/urfave/negroni"
 )
 
-// gracefulTermTimeout is the amount of time to wait for all HTTP requests
-// to complete before forcing the server to shut down
+// gracefulTermTimeout is the default amount of time to wait for all HTTP requests
+// and shutdown hooks to complete before forcing the server to shut down
 const gracefulTermTimeout = 30 * time.Second
 
-// stopSignals contains all of the OS Signals to respond to during
+// Default http.Server timeouts and limits to protect against slow clients
+const (
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+)
+
+// stopSignals contains the default OS Signals to respond to during
 // a graceful shutdown of the HTTP server.
 var stopSignals = []os.Signal{
 	syscall.SIGHUP,
@@ -35,45 +51,224 @@ var ErrServerStopped = errors.New("common/server: not running")
 var ErrServerAlreadyRunning = errors.New("common/server: already running")
 
 // Server struct containing the httprouter routing handler
-// and the negroni middleware framework
+// and the ordered middleware stack in front of it
 type Server struct {
 	*httprouter.Router
-	*negroni.Negroni
 
 	contextPath string
 
-	routes []RouteInfo
+	routes     []RouteInfo
+	middleware []middleware
+
+	tls              tlsOptions
+	socketActivation bool
+	unixSocketMode   os.FileMode
 
+	mu               sync.Mutex
 	httpServer       *http.Server
-	readinessMetrics []HealthMetric
-	livenessMetrics  []HealthMetric
+	listener         net.Listener
+	done             chan struct{}
+	serveErr         error
+	readinessMetrics healthChecks
+	livenessMetrics  healthChecks
+	startupMetrics   healthChecks
 	infoMetrics      []InfoMetric
+	requests         requestMetrics
+	tracer           tracer
+	logger           *slog.Logger
+	accessLog        accessLogger
+
+	shutdownTimeout time.Duration
+	stopSignals     []os.Signal
+	shutdownHooks   []shutdownHook
+	drainDelay      time.Duration
+	upgradeSignal   os.Signal
+	draining        int32
+	started         int32
+
+	admin adminServer
+	http  httpOptions
+
+	healthCheckTimeout time.Duration
+	healthContentType  string
+
+	healthMu          sync.Mutex
+	healthListeners   []func(HealthChange)
+	healthHistory     []HealthChange
+	healthHistorySize int
+}
+
+// httpOptions holds the http.Server tuning collected from the server options
+type httpOptions struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	errorLog          *log.Logger
+
+	disableKeepAlivesOnDrain bool
+}
+
+// newHTTPServer creates an http.Server with the timeouts, limits and error log applied
+func (o httpOptions) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       o.readTimeout,
+		ReadHeaderTimeout: o.readHeaderTimeout,
+		WriteTimeout:      o.writeTimeout,
+		IdleTimeout:       o.idleTimeout,
+		MaxHeaderBytes:    o.maxHeaderBytes,
+		ErrorLog:          o.errorLog,
+	}
+}
+
+// ShutdownHook is the func called while the server is shutting down. The context
+// carries the deadline remaining from the shutdown timeout.
+type ShutdownHook func(ctx context.Context) error
+
+// shutdownHook is a named hook registered with OnShutdown
+type shutdownHook struct {
+	name string
+	hook ShutdownHook
 }
 
 // New creates a new instance of the router. Context path is the prefix to all url paths.
 func New(opts ...Option) *Server {
-	srv := &Server{Router: httprouter.New(), Negroni: negroni.Classic()}
+	srv := &Server{Router: httprouter.New()}
+	srv.shutdownTimeout = gracefulTermTimeout
+	srv.healthCheckTimeout = defaultHealthCheckTimeout
+	srv.healthContentType = defaultHealthContentType
+	srv.healthHistorySize = defaultHealthHistorySize
+	srv.requests.buckets = defaultRequestDurationBuckets
+	srv.accessLog = accessLogger{server: srv, format: AccessLogStructured, sampleRate: 1}
+	srv.readinessMetrics.endpoint = "readiness"
+	srv.readinessMetrics.onChange = srv.healthChanged
+	srv.livenessMetrics.endpoint = "liveness"
+	srv.livenessMetrics.onChange = srv.healthChanged
+	srv.startupMetrics.endpoint = "startup"
+	srv.startupMetrics.onChange = srv.healthChanged
+	srv.stopSignals = stopSignals
+	srv.tls.reloadInterval = defaultTLSReloadInterval
+	srv.tls.expiryThreshold = defaultTLSExpiryThreshold
+	srv.http = httpOptions{
+		readTimeout:       defaultReadTimeout,
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		idleTimeout:       defaultIdleTimeout,
+		maxHeaderBytes:    defaultMaxHeaderBytes,
+	}
 
 	srv.HandleMethodNotAllowed = true
 	srv.MethodNotAllowed = MethodNotAllowedHandler()
 	srv.NotFound = NotFoundHandler()
-	srv.PanicHandler = PanicHandler()
+	srv.PanicHandler = srv.panicHandler
 
+	devMode := false
+	disableRequestID := false
+	defaults := defaultMiddleware
 	for _, o := range opts {
 		switch o.name {
 		case optionContextPath:
 			srv.contextPath = strings.TrimSuffix(o.value.(string), "/")
 		case optionAppEnv:
 			if o.value == "dev" || o.value == "test" {
-				srv.GET("/_system/routes", RouteHandler(&srv.routes))
-				srv.PanicHandler = nil
+				devMode = true
 			}
+		case optionTLSCertFiles:
+			files := o.value.([2]string)
+			srv.tls.certFile, srv.tls.keyFile = files[0], files[1]
+		case optionTLSConfig:
+			srv.tls.config = o.value.(*tls.Config)
+		case optionTLSClientCAFile:
+			srv.tls.clientCAFile = o.value.(string)
+		case optionTLSClientAuth:
+			srv.tls.clientAuth = o.value.(tls.ClientAuthType)
+		case optionTLSReloadInterval:
+			srv.tls.reloadInterval = o.value.(time.Duration)
+		case optionTLSExpiryThreshold:
+			srv.tls.expiryThreshold = o.value.(time.Duration)
+		case optionSocketActivation:
+			srv.socketActivation = o.value.(bool)
+		case optionUnixSocketMode:
+			srv.unixSocketMode = o.value.(os.FileMode)
+		case optionShutdownTimeout:
+			srv.shutdownTimeout = o.value.(time.Duration)
+		case optionStopSignals:
+			srv.stopSignals = o.value.([]os.Signal)
+		case optionDrainDelay:
+			srv.drainDelay = o.value.(time.Duration)
+		case optionUpgradeSignal:
+			srv.upgradeSignal = o.value.(os.Signal)
+		case optionAdminAddr:
+			srv.admin.addr = o.value.(string)
+		case optionAdminListener:
+			srv.admin.provided = o.value.(net.Listener)
+		case optionReadTimeout:
+			srv.http.readTimeout = o.value.(time.Duration)
+		case optionReadHeaderTimeout:
+			srv.http.readHeaderTimeout = o.value.(time.Duration)
+		case optionWriteTimeout:
+			srv.http.writeTimeout = o.value.(time.Duration)
+		case optionIdleTimeout:
+			srv.http.idleTimeout = o.value.(time.Duration)
+		case optionMaxHeaderBytes:
+			srv.http.maxHeaderBytes = o.value.(int)
+		case optionErrorLog:
+			srv.http.errorLog = o.value.(*log.Logger)
+		case optionDisableKeepAlivesOnDrain:
+			srv.http.disableKeepAlivesOnDrain = o.value.(bool)
+		case optionHealthCheckTimeout:
+			srv.healthCheckTimeout = o.value.(time.Duration)
+		case optionHealthContentType:
+			srv.healthContentType = o.value.(string)
+		case optionHealthHistorySize:
+			srv.healthHistorySize = o.value.(int)
+		case optionRequestDurationBuckets:
+			srv.requests.buckets = append([]float64{}, o.value.([]float64)...)
+			sort.Float64s(srv.requests.buckets)
+		case optionTracing:
+			srv.tracer.exporter, _ = o.value.(SpanExporter)
+		case optionDisableRequestID:
+			disableRequestID = o.value.(bool)
+		case optionLogger:
+			srv.logger = o.value.(*slog.Logger)
+		case optionAccessLogFormat:
+			srv.accessLog.format = o.value.(AccessLogFormat)
+		case optionAccessLogOutput:
+			srv.accessLog.out = o.value.(io.Writer)
+		case optionAccessLogSampleRate:
+			srv.accessLog.sampleRate = o.value.(float64)
+		case optionAccessLogExclude:
+			srv.accessLog.exclude = append(srv.accessLog.exclude, o.value.([]string)...)
+		case optionDefaultMiddleware:
+			defaults = o.value.([]DefaultMiddleware)
 		}
 	}
 
-	srv.GET("/_system/readiness", HealthHandler(&srv.readinessMetrics))
-	srv.GET("/_system/liveness", HealthHandler(&srv.livenessMetrics))
-	srv.GET("/_system/info", InfoHandler(&srv.infoMetrics))
+	if srv.logger != nil && srv.http.errorLog == nil {
+		srv.http.errorLog = slog.NewLogLogger(srv.logger.Handler(), slog.LevelError)
+	}
+
+	for _, name := range defaults {
+		if name == MiddlewareRequestID && disableRequestID {
+			continue
+		}
+		srv.useDefault(name)
+	}
+
+	if devMode {
+		srv.handleSystem("GET", "/_system/routes", RouteHandler(&srv.routes))
+		srv.handleSystem("GET", "/_system/middleware", srv.middlewareHandler)
+	}
+
+	srv.handleSystem("GET", "/_system/startup", srv.startupHandler)
+	srv.handleSystem("GET", "/_system/readiness", srv.drainAwareHandler(srv.startupAwareHandler(healthHandler(srv.readinessMetrics.list, srv.healthCheckTimeout, srv.healthContentType))))
+	srv.handleSystem("GET", "/_system/health/history", srv.healthHistoryHandler)
+	srv.handleSystem("GET", "/_system/liveness", healthHandler(srv.livenessMetrics.list, srv.healthCheckTimeout, srv.healthContentType))
+	srv.handleSystem("GET", "/_system/info", infoHandler(&srv.infoMetrics, srv.requests.list))
+	srv.handleSystem("GET", "/_system/metrics", srv.metricsHandler)
 
 	return srv
 }
@@ -81,18 +276,20 @@ func New(opts ...Option) *Server {
 // AddLivenessCheck to the list of liveness metrics used to validate the system
 // is running and healthy at the /_system/liveness endpoint. The name parameter
 // should be camel-case. Liveness metrics are defined as the server has moved into
-// a broken state and cannot recover except by being restarted.
-func (s *Server) AddLivenessCheck(name string, handler HealthMetricHandler) {
-	s.livenessMetrics = append(s.livenessMetrics, HealthMetric{Name: name, GetValue: handler})
+// a broken state and cannot recover except by being restarted. The check options
+// such as CheckTimeout apply to this check only.
+func (s *Server) AddLivenessCheck(name string, handler HealthMetricHandler, opts ...HealthCheckOption) {
+	s.livenessMetrics.add(newHealthMetric(name, handler, opts))
 }
 
 // AddReadinessCheck to the list of readiness metrics used to validate the system
 // is running and healthy at the /_system/readiness endpoint. The name parameter
 // should be camel-case. Readiness metrics are used when the server is temporarily unable
 // to serve traffic this is different from liveness in that readiness should not restart
-// the application when it is failing.
-func (s *Server) AddReadinessCheck(name string, handler HealthMetricHandler) {
-	s.readinessMetrics = append(s.readinessMetrics, HealthMetric{Name: name, GetValue: handler})
+// the application when it is failing. The check options such as CheckTimeout apply
+// to this check only.
+func (s *Server) AddReadinessCheck(name string, handler HealthMetricHandler, opts ...HealthCheckOption) {
+	s.readinessMetrics.add(newHealthMetric(name, handler, opts))
 }
 
 // AddInfoMetric to the list of info metrics used to get info about the running system
@@ -101,108 +298,381 @@ func (s *Server) AddInfoMetric(name string, handler InfoMetricHandler) {
 	s.infoMetrics = append(s.infoMetrics, InfoMetric{Name: name, GetValue: handler})
 }
 
-// Run the HTTP server on the addr provided with graceful shutdown
-func (s *Server) Run(addr string) error {
-	// If the server is already running return error
-	if s.httpServer != nil {
-		return ErrServerAlreadyRunning
-	}
+// OnShutdown registers a hook that is called by Shutdown after the HTTP requests have
+// drained, such as flushing queues or closing database pools. Hooks are called in the
+// reverse order they were registered and share the remaining shutdown timeout. Errors
+// returned by the hooks are joined into the error returned by Shutdown.
+func (s *Server) OnShutdown(name string, hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	s.Negroni.UseHandler(s.Router)
+	s.shutdownHooks = append(s.shutdownHooks, shutdownHook{name: name, hook: hook})
+}
 
-	s.httpServer = &http.Server{Addr: addr, Handler: s.Negroni}
+// Run the HTTP server on the addr provided with graceful shutdown.
+// Addresses prefixed with "unix:" such as "unix:/run/app.sock" are served on a unix
+// domain socket and the addr is ignored when OptionSocketActivation is set.
+func (s *Server) Run(addr string) error {
+	return s.runUntilSignal(func() error { return s.Start(addr) })
+}
 
-	// Start the server in a gorutine
-	errChan := make(chan error)
-	go s.startServer(errChan)
+// RunTLS runs the HTTPS server on the addr provided with graceful shutdown.
+// The certificates are configured with OptionTLSCertFiles or OptionTLSConfig and
+// client certificate verification is enabled with OptionTLSClientCAFile.
+func (s *Server) RunTLS(addr string) error {
+	return s.runUntilSignal(func() error { return s.StartTLS(addr) })
+}
 
-	// Wait for an OS Signal
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, stopSignals...)
+// RunContext runs the HTTP server on the addr provided until the context is cancelled
+// and then gracefully shuts down. No OS signal handlers are installed so the caller is
+// in control of when the server stops.
+func (s *Server) RunContext(ctx context.Context, addr string) error {
+	if err := s.Start(addr); err != nil {
+		return err
+	}
 
-	// Wait for either the start error or the OS signal
 	select {
-	case <-stop:
+	case <-ctx.Done():
+		s.drain(nil)
 		return s.Shutdown()
 
-	case err := <-errChan:
+	case <-s.Done():
+		return s.Wait()
+	}
+}
+
+// Serve HTTP requests on the listener provided with graceful shutdown.
+// The listener is closed when the server is shut down.
+func (s *Server) Serve(l net.Listener) error {
+	return s.runUntilSignal(func() error { return s.start(l, nil) })
+}
+
+// ServeTLS serves HTTPS requests on the listener provided with graceful shutdown.
+// The TLS options are the same as RunTLS and the listener is closed when the server is shut down.
+func (s *Server) ServeTLS(l net.Listener) error {
+	tlsConfig, err := s.buildTLSConfig()
+	if err != nil {
 		return err
 	}
+
+	return s.runUntilSignal(func() error { return s.start(l, tlsConfig) })
+}
+
+// Start the HTTP server on the addr provided without blocking. Start returns once the
+// socket is bound and accepting connections or with the error binding the socket.
+// Use Done or Wait to observe when the server stops and Shutdown to stop it.
+func (s *Server) Start(addr string) error {
+	return s.listenAndStart(addr, nil)
+}
+
+// StartTLS starts the HTTPS server on the addr provided without blocking.
+// The TLS options are the same as RunTLS and the return semantics are the same as Start.
+func (s *Server) StartTLS(addr string) error {
+	// If the server is already running return error
+	if s.IsRunning() {
+		return ErrServerAlreadyRunning
+	}
+
+	tlsConfig, err := s.buildTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	return s.listenAndStart(addr, tlsConfig)
+}
+
+// Done returns a channel that is closed when the server stops, either from
+// Shutdown completing or from a failure serving requests. When the server
+// has not been started the returned channel is already closed.
+func (s *Server) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+
+	return s.done
+}
+
+// Wait blocks until the server stops and returns the error that stopped it.
+// The error is nil when the server was stopped with Shutdown.
+func (s *Server) Wait() error {
+	<-s.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serveErr
 }
 
 // Shutdown gracefully stops the HTTP server
 func (s *Server) Shutdown() (err error) {
-	if s.httpServer == nil {
+	s.mu.Lock()
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if httpServer == nil {
 		return ErrServerStopped
 	}
 
-	log.Println("Shutting down HTTP server...")
+	s.Logger().Info("Shutting down HTTP server")
+
+	if s.tls.reloader != nil {
+		s.tls.reloader.stopWatching()
+	}
 
 	// Create a timeout context to force kill requests if they take more than an allotted time
-	ctx, cancel := context.WithTimeout(context.Background(), gracefulTermTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
-	err = s.httpServer.Shutdown(ctx)
-	s.httpServer = nil
+	err = httpServer.Shutdown(ctx)
+	if adminErr := s.shutdownAdmin(ctx); adminErr != nil {
+		err = errors.Join(err, adminErr)
+	}
+	if hookErr := s.runShutdownHooks(ctx); hookErr != nil {
+		err = errors.Join(err, hookErr)
+	}
+	if s.tracer.exporter != nil {
+		if traceErr := s.tracer.exporter.Shutdown(ctx); traceErr != nil {
+			err = errors.Join(err, traceErr)
+		}
+	}
+
+	s.stopped(httpServer, nil)
 	return err
 }
 
+// runShutdownHooks calls the registered shutdown hooks in reverse order and joins their errors
+func (s *Server) runShutdownHooks(ctx context.Context) error {
+	s.mu.Lock()
+	hooks := append([]shutdownHook(nil), s.shutdownHooks...)
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		s.Logger().Info("Running shutdown hook", "hook", hooks[i].name)
+		if err := hooks[i].hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("common/server: shutdown hook %s: %w", hooks[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // IsRunning tells if the server is currently running
-func (s Server) IsRunning() bool {
+func (s *Server) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.httpServer != nil
 }
 
-// start the server and fatally log failure if there is a failure starting the server
-func (s *Server) startServer(errChan chan error) {
+// Addr returns the address the server is listening on or nil when the server is
+// not running. This is used to read back the port chosen when listening on ":0".
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+// listenAndStart binds the addr and starts serving it with an optional TLS config
+func (s *Server) listenAndStart(addr string, tlsConfig *tls.Config) error {
+	// If the server is already running return error
+	if s.IsRunning() {
+		return ErrServerAlreadyRunning
+	}
+
+	l, err := s.listen(httpListenerName, addr)
+	if err != nil {
+		return errors.New("common/server: failed to start server: " + err.Error())
+	}
+
+	if err := s.start(l, tlsConfig); err != nil {
+		l.Close()
+		return err
+	}
+
+	notifyUpgradeReady()
+	return nil
+}
+
+// start serving the listener with an optional TLS config in a goroutine
+func (s *Server) start(l net.Listener, tlsConfig *tls.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// If the server is already running return error
+	if s.httpServer != nil {
+		return ErrServerAlreadyRunning
+	}
+
+	if err := s.startAdmin(); err != nil {
+		return err
+	}
+
+	s.listener = l
+	s.httpServer = s.http.newHTTPServer(l.Addr().String(), s.handler())
+	s.httpServer.TLSConfig = tlsConfig
+	s.done = make(chan struct{})
+	s.serveErr = nil
+
+	s.readinessMetrics.poll(s.healthCheckTimeout)
+	s.livenessMetrics.poll(s.healthCheckTimeout)
+	s.startupMetrics.poll(s.healthCheckTimeout)
+
+	if tlsConfig != nil && s.tls.reloader != nil {
+		s.tls.reloader.watch(s.tls.reloadInterval)
+	}
+
+	// Start the server in a gorutine
+	go s.startServer(s.httpServer, l)
+
+	return nil
+}
+
+// runUntilSignal starts the server and waits for either the server to stop or an
+// OS signal to gracefully shut down. The signals are watched before the server is
+// started so a signal received during startup is not missed.
+func (s *Server) runUntilSignal(start func() error) error {
+	// signal.Notify without any signals relays every signal, so it is skipped to turn
+	// off signal handling when the stop signals were set to an empty list
+	stop := make(chan os.Signal, 1)
+	if len(s.stopSignals) > 0 {
+		signal.Notify(stop, s.stopSignals...)
+		defer signal.Stop(stop)
+	}
+
+	upgrade := make(chan os.Signal, 1)
+	if s.upgradeSignal != nil {
+		signal.Notify(upgrade, s.upgradeSignal)
+		defer signal.Stop(upgrade)
+	}
+
+	if err := start(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stop:
+			s.drain(stop)
+			return s.Shutdown()
+
+		case <-upgrade:
+			s.Logger().Info("Upgrading HTTP server")
+			if err := s.Upgrade(); err != nil {
+				s.Logger().Error("Failed to upgrade HTTP server", "error", err)
+				continue
+			}
+			return s.Shutdown()
+
+		case <-s.Done():
+			return s.Wait()
+		}
+	}
+}
+
+// start the server and record the failure if there is a failure serving requests
+func (s *Server) startServer(httpServer *http.Server, l net.Listener) {
 	// Start the server
-	log.Printf("Starting HTTP server at %s\n", s.httpServer.Addr)
-	err := s.httpServer.ListenAndServe()
+	var err error
+	if httpServer.TLSConfig != nil {
+		s.Logger().Info("Starting HTTPS server", "addr", httpServer.Addr)
+		err = httpServer.ServeTLS(l, "", "")
+	} else {
+		s.Logger().Info("Starting HTTP server", "addr", httpServer.Addr)
+		err = httpServer.Serve(l)
+	}
 
-	// Log error if the server was not closed
+	// Record the error if the server was not closed
 	if err != nil && err != http.ErrServerClosed {
-		s.httpServer = nil
-		errChan <- errors.New("common/server: failed to start server: " + err.Error())
+		s.stopped(httpServer, errors.New("common/server: failed to start server: "+err.Error()))
+	}
+}
+
+// stopped clears the running state of the server and releases anything waiting on Done
+func (s *Server) stopped(httpServer *http.Server, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.httpServer != httpServer {
+		return
 	}
+
+	if s.tls.reloader != nil {
+		s.tls.reloader.stopWatching()
+	}
+
+	// The listener is only closed by the http server once it is serving, which may
+	// not have happened yet when the server is shut down right after starting
+	s.listener.Close()
+
+	s.readinessMetrics.stopPolling()
+	s.livenessMetrics.stopPolling()
+	s.startupMetrics.stopPolling()
+
+	s.stoppedAdmin()
+	s.httpServer = nil
+	s.listener = nil
+	s.serveErr = err
+	atomic.StoreInt32(&s.draining, 0)
+	close(s.done)
 }
 
 // Handle is a function that can be registered to a route to handle HTTP requests.
 // Like http.HandlerFunc, but has a third parameter for the values of wildcards (variables).
-func (s *Server) Handle(method, path string, handle httprouter.Handle) {
-	s.routes = append(s.routes, RouteInfo{Method: method, Path: s.contextPath + path})
-	s.Router.Handle(method, s.contextPath+path, handle)
+// The requests served by the route are counted in the metrics endpoint and traced by the registered path.
+// The route options such as RouteMiddleware and RouteTimeout apply to this route only.
+func (s *Server) Handle(method, path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.handle(method, path, nil, handle, opts)
+}
+
+// handle registers the route with the group middleware followed by the route options
+func (s *Server) handle(method, path string, group []middleware, handle httprouter.Handle, opts []RouteOption) {
+	route := s.contextPath + path
+	info, chain := newRoute(method, route, group, opts)
+	s.routes = append(s.routes, info)
+	s.Router.Handle(method, route, s.requests.instrument(method, route, traceRoute(method, route, logRoute(route, chainHandle(chain, handle)))))
 }
 
-// GET is a shortcut for router.Handle("GET", path, handle)
-func (s *Server) GET(path string, handle httprouter.Handle) {
-	s.Handle("GET", path, handle)
+// GET is a shortcut for router.Handle("GET", path, handle, opts...)
+func (s *Server) GET(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("GET", path, handle, opts...)
 }
 
-// POST is a shortcut for router.Handle("POST", path, handle)
-func (s *Server) POST(path string, handle httprouter.Handle) {
-	s.Handle("POST", path, handle)
+// POST is a shortcut for router.Handle("POST", path, handle, opts...)
+func (s *Server) POST(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("POST", path, handle, opts...)
 }
 
-// PUT is a shortcut for router.Handle("PUT", path, handle)
-func (s *Server) PUT(path string, handle httprouter.Handle) {
-	s.Handle("PUT", path, handle)
+// PUT is a shortcut for router.Handle("PUT", path, handle, opts...)
+func (s *Server) PUT(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("PUT", path, handle, opts...)
 }
 
-// PATCH is a shortcut for router.Handle("PATCH", path, handle)
-func (s *Server) PATCH(path string, handle httprouter.Handle) {
-	s.Handle("PATCH", path, handle)
+// PATCH is a shortcut for router.Handle("PATCH", path, handle, opts...)
+func (s *Server) PATCH(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("PATCH", path, handle, opts...)
 }
 
-// DELETE is a shortcut for router.Handle("DELETE", path, handle)
-func (s *Server) DELETE(path string, handle httprouter.Handle) {
-	s.Handle("DELETE", path, handle)
+// DELETE is a shortcut for router.Handle("DELETE", path, handle, opts...)
+func (s *Server) DELETE(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("DELETE", path, handle, opts...)
 }
 
-// HEAD is a shortcut for router.Handle("HEAD", path, handle)
-func (s *Server) HEAD(path string, handle httprouter.Handle) {
-	s.Handle("HEAD", path, handle)
+// HEAD is a shortcut for router.Handle("HEAD", path, handle, opts...)
+func (s *Server) HEAD(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("HEAD", path, handle, opts...)
 }
 
-// OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle)
-func (s *Server) OPTIONS(path string, handle httprouter.Handle) {
-	s.Handle("OPTIONS", path, handle)
+// OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle, opts...)
+func (s *Server) OPTIONS(path string, handle httprouter.Handle, opts ...RouteOption) {
+	s.Handle("OPTIONS", path, handle, opts...)
 }
@@ -1,12 +1,19 @@
 package srv_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
-	"syscall"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,7 +45,7 @@ func testNew_Success(t *testing.T) {
 	infoHandler, _, _ := got.Lookup("GET", "/_system/info")
 
 	assert.NotNil(got.Router)
-	assert.NotNil(got.Negroni)
+	assert.Equal([]string{"requestId", "recovery", "accessLog", "requestLogger"}, got.Middleware())
 	assert.Nil(routesHandler)
 	assert.NotNil(readinessHandler)
 	assert.NotNil(livenessHandler)
@@ -86,14 +93,14 @@ func testNew_ContextPath(t *testing.T) {
 	assert.NotNil(infoHandler)
 }
 
-func Testsrv_AddLivenessCheck(t *testing.T) {
+func TestServer_AddLivenessCheck(t *testing.T) {
 	// Arrange
 	checkName := "testCheck"
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.AddLivenessCheck(checkName, func() srv.HealthMetricResult {
+	s.AddLivenessCheck(checkName, func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: true}
 	})
 
@@ -101,19 +108,19 @@ func Testsrv_AddLivenessCheck(t *testing.T) {
 	var parsedRes srv.HealthResponse
 	res := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/_system/liveness", nil)
-	srv.Router.ServeHTTP(res, req)
-	json.NewDecoder(res.Result().Body).Decode(&parsedRes)
+	s.Router.ServeHTTP(res, req)
+	decodeJres(res.Code, res.Body, &parsedRes)
 	assert.Equal("ok", parsedRes.Metrics[checkName].Status)
 }
 
-func Testsrv_AddReadinessCheck(t *testing.T) {
+func TestServer_AddReadinessCheck(t *testing.T) {
 	// Arrange
 	checkName := "testCheck"
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.AddReadinessCheck(checkName, func() srv.HealthMetricResult {
+	s.AddReadinessCheck(checkName, func(ctx context.Context) srv.HealthMetricResult {
 		return srv.HealthMetricResult{OK: true}
 	})
 
@@ -121,111 +128,699 @@ func Testsrv_AddReadinessCheck(t *testing.T) {
 	var parsedRes srv.HealthResponse
 	res := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/_system/readiness", nil)
-	srv.Router.ServeHTTP(res, req)
-	json.NewDecoder(res.Result().Body).Decode(&parsedRes)
+	s.Router.ServeHTTP(res, req)
+	decodeJres(res.Code, res.Body, &parsedRes)
 	assert.Equal("ok", parsedRes.Metrics[checkName].Status)
 }
 
-func Testsrv_Run(t *testing.T) {
-	t.Run("Success", testsrv_Run_Success)
-	t.Run("srvStopSignalSuccess", testsrv_Run_StopSignalSuccess)
-	t.Run("srvRunningError", testsrv_Run_srvRunningError)
-	t.Run("srvListenError", testsrv_Run_ListenError)
+func TestServer_AddStartupCheck(t *testing.T) {
+	t.Run("Starting", testServer_AddStartupCheck_Starting)
+	t.Run("Latches", testServer_AddStartupCheck_Latches)
+	t.Run("NonCritical", testServer_AddStartupCheck_NonCritical)
+	t.Run("NoChecks", testServer_AddStartupCheck_NoChecks)
 }
 
-func testsrv_Run_Success(t *testing.T) {
+func testServer_AddStartupCheck_Starting(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
 	s := srv.New()
+	s.AddStartupCheck("warmup", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	})
 
 	// Act
-	go func() { err = s.Run(defaultAddr) }()
-	defer s.Shutdown()
-	waitForAddr(defaultAddr)
+	startupRes, startup := getHealth(s, "/_system/startup")
+	readinessRes, readiness := getHealth(s, "/_system/readiness")
 
 	// Assert
-	assert.Nil(err)
+	assert.Equal(http.StatusInternalServerError, startupRes.Code)
+	assert.Equal("starting", startup.Status)
+	assert.Equal("not ok", startup.Metrics["warmup"].Status)
+	assert.Equal(http.StatusInternalServerError, readinessRes.Code)
+	assert.Equal("starting", readiness.Metrics["startup"].Status)
+	assert.False(s.Started())
+}
+
+func testServer_AddStartupCheck_Latches(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var calls int32
+	s := srv.New()
+	s.AddStartupCheck("warmup", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: atomic.AddInt32(&calls, 1) == 2}
+	})
+
+	// Act
+	first, _ := getHealth(s, "/_system/startup")
+	second, _ := getHealth(s, "/_system/startup")
+	third, parsedRes := getHealth(s, "/_system/startup")
+	readinessRes, _ := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, first.Code)
+	assert.Equal(http.StatusOK, second.Code)
+	assert.Equal(http.StatusOK, third.Code)
+	assert.Equal("ok", parsedRes.Status)
+	assert.Equal(http.StatusOK, readinessRes.Code)
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+	assert.True(s.Started())
+}
+
+func testServer_AddStartupCheck_NonCritical(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.AddStartupCheck("warm", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	}, srv.CheckCritical(false))
+
+	// Act
+	startupRes, startup := getHealth(s, "/_system/startup")
+	readinessRes, _ := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, startupRes.Code)
+	assert.Equal("starting", startup.Status)
+	assert.Equal("not ok", startup.Metrics["warm"].Status)
+	assert.Equal(http.StatusInternalServerError, readinessRes.Code)
+	assert.False(s.Started())
+}
+
+func testServer_AddStartupCheck_NoChecks(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act
+	readinessRes, _ := getHealth(s, "/_system/readiness")
+	startupRes, parsedRes := getHealth(s, "/_system/startup")
+
+	// Assert
+	assert.Equal(http.StatusOK, readinessRes.Code)
+	assert.Equal(http.StatusOK, startupRes.Code)
+	assert.Equal("ok", parsedRes.Status)
+}
+
+func TestServer_HealthCheckTimeout(t *testing.T) {
+	t.Run("CheckTimeout", testServer_HealthCheckTimeout_CheckTimeout)
+	t.Run("DefaultTimeout", testServer_HealthCheckTimeout_DefaultTimeout)
+	t.Run("ConcurrentAdd", testServer_HealthCheckTimeout_ConcurrentAdd)
+}
+
+func testServer_HealthCheckTimeout_CheckTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.AddReadinessCheck("hung", func(ctx context.Context) srv.HealthMetricResult {
+		<-ctx.Done()
+		return srv.HealthMetricResult{OK: true}
+	}, srv.CheckTimeout(10*time.Millisecond))
+
+	// Act
+	var parsedRes srv.HealthResponse
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/_system/readiness", nil)
+	s.Router.ServeHTTP(res, req)
+	decodeJres(res.Code, res.Body, &parsedRes)
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.Equal("timeout", parsedRes.Metrics["hung"].Status)
+}
+
+func testServer_HealthCheckTimeout_DefaultTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionHealthCheckTimeout(10 * time.Millisecond))
+	s.AddLivenessCheck("hung", func(ctx context.Context) srv.HealthMetricResult {
+		<-ctx.Done()
+		return srv.HealthMetricResult{OK: true}
+	})
+
+	// Act
+	var parsedRes srv.HealthResponse
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/_system/liveness", nil)
+	s.Router.ServeHTTP(res, req)
+	decodeJres(res.Code, res.Body, &parsedRes)
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.Equal("timeout", parsedRes.Metrics["hung"].Status)
 }
 
-func testsrv_Run_StopSignalSuccess(t *testing.T) {
+func testServer_HealthCheckTimeout_ConcurrentAdd(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
-	doneCh := make(chan bool)
 	s := srv.New()
+	done := make(chan struct{})
 
+	// Act
 	go func() {
-		err = s.Run(defaultAddr)
-		doneCh <- true
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			s.AddReadinessCheck(fmt.Sprint("check", i), func(ctx context.Context) srv.HealthMetricResult {
+				return srv.HealthMetricResult{OK: true}
+			})
+		}
 	}()
-	defer s.Shutdown()
-	waitForAddr(defaultAddr)
-	assert.Nil(err)
+	for i := 0; i < 20; i++ {
+		res := httptest.NewRecorder()
+		s.Router.ServeHTTP(res, httptest.NewRequest("GET", "/_system/readiness", nil))
+		assert.Equal(http.StatusOK, res.Code)
+	}
+	<-done
+
+	// Assert
+	var parsedRes srv.HealthResponse
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, httptest.NewRequest("GET", "/_system/readiness", nil))
+	decodeJres(res.Code, res.Body, &parsedRes)
+	assert.Len(parsedRes.Metrics, 20)
+}
+
+func TestServer_HealthCheckModes(t *testing.T) {
+	t.Run("CacheTTL", testServer_HealthCheckModes_CacheTTL)
+	t.Run("Interval", testServer_HealthCheckModes_Interval)
+	t.Run("IntervalNotPolling", testServer_HealthCheckModes_IntervalNotPolling)
+	t.Run("IntervalAfterShutdown", testServer_HealthCheckModes_IntervalAfterShutdown)
+	t.Run("LastTransition", testServer_HealthCheckModes_LastTransition)
+}
+
+func testServer_HealthCheckModes_CacheTTL(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var calls int32
+	s := srv.New()
+	s.AddReadinessCheck("cached", func(ctx context.Context) srv.HealthMetricResult {
+		atomic.AddInt32(&calls, 1)
+		return srv.HealthMetricResult{OK: true}
+	}, srv.CheckCacheTTL(time.Hour))
 
 	// Act
-	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
-	<-doneCh
+	getHealth(s, "/_system/readiness")
+	_, parsedRes := getHealth(s, "/_system/readiness")
 
 	// Assert
-	assert.Nil(err)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	assert.Equal("ok", parsedRes.Metrics["cached"].Status)
+	assert.NotEmpty(parsedRes.Metrics["cached"].Age)
+	assert.NotNil(parsedRes.Metrics["cached"].LastTransition)
 }
 
-func testsrv_Run_srvRunningError(t *testing.T) {
+func testServer_HealthCheckModes_Interval(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
+	var calls int32
 	s := srv.New()
+	s.AddLivenessCheck("polled", func(ctx context.Context) srv.HealthMetricResult {
+		atomic.AddInt32(&calls, 1)
+		return srv.HealthMetricResult{OK: true}
+	}, srv.CheckInterval(10*time.Millisecond))
 
-	go func() { err = s.Run(defaultAddr) }()
+	// Act
+	s.Start("127.0.0.1:0")
+	waitFor(func() bool { return atomic.LoadInt32(&calls) >= 3 })
+	res, parsedRes := getHealth(s, "/_system/liveness")
+	s.Shutdown()
+	time.Sleep(20 * time.Millisecond)
+	stopped := atomic.LoadInt32(&calls)
+	time.Sleep(50 * time.Millisecond)
+
+	// Assert
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal("ok", parsedRes.Metrics["polled"].Status)
+	assert.NotEmpty(parsedRes.Metrics["polled"].Age)
+	assert.True(stopped >= 3)
+	assert.Equal(stopped, atomic.LoadInt32(&calls))
+}
+
+func testServer_HealthCheckModes_IntervalNotPolling(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var healthy int32 = 1
+	s := srv.New()
+	s.AddReadinessCheck("polled", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: atomic.LoadInt32(&healthy) == 1}
+	}, srv.CheckInterval(50*time.Millisecond))
+
+	// Act
+	first, _ := getHealth(s, "/_system/readiness")
+	atomic.StoreInt32(&healthy, 0)
+	cached, _ := getHealth(s, "/_system/readiness")
+	time.Sleep(60 * time.Millisecond)
+	expired, parsedRes := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Equal(http.StatusOK, first.Code)
+	assert.Equal(http.StatusOK, cached.Code)
+	assert.Equal(http.StatusInternalServerError, expired.Code)
+	assert.Equal("not ok", parsedRes.Metrics["polled"].Status)
+}
+
+func testServer_HealthCheckModes_IntervalAfterShutdown(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var healthy int32 = 1
+	var calls int32
+	s := srv.New()
+	s.AddReadinessCheck("polled", func(ctx context.Context) srv.HealthMetricResult {
+		atomic.AddInt32(&calls, 1)
+		return srv.HealthMetricResult{OK: atomic.LoadInt32(&healthy) == 1}
+	}, srv.CheckInterval(50*time.Millisecond))
+	s.Start("127.0.0.1:0")
+	waitFor(func() bool { return atomic.LoadInt32(&calls) >= 1 })
+	s.Shutdown()
+
+	// Act
+	atomic.StoreInt32(&healthy, 0)
+	time.Sleep(60 * time.Millisecond)
+	res, parsedRes := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.Equal("not ok", parsedRes.Metrics["polled"].Status)
+}
+
+func testServer_HealthCheckModes_LastTransition(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var healthy int32 = 1
+	s := srv.New()
+	s.AddReadinessCheck("flapping", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: atomic.LoadInt32(&healthy) == 1}
+	})
+
+	// Act
+	_, first := getHealth(s, "/_system/readiness")
+	_, same := getHealth(s, "/_system/readiness")
+	atomic.StoreInt32(&healthy, 0)
+	_, changed := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Empty(first.Metrics["flapping"].Age)
+	assert.True(first.Metrics["flapping"].LastTransition.Equal(*same.Metrics["flapping"].LastTransition))
+	assert.True(changed.Metrics["flapping"].LastTransition.After(*same.Metrics["flapping"].LastTransition))
+}
+
+func TestServer_HealthCheckCritical(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.AddReadinessCheck("recommendations", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	}, srv.CheckCritical(false))
+
+	// Act
+	res, parsedRes := getHealth(s, "/_system/readiness")
+
+	// Assert
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal("degraded", parsedRes.Status)
+	assert.Equal("not ok", parsedRes.Metrics["recommendations"].Status)
+}
+
+func TestServer_HealthContentType(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionHealthContentType(srv.HealthJSONContentType))
+	s.AddLivenessCheck("broken", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: false}
+	})
+	jsonReq := httptest.NewRequest("GET", "/_system/liveness", nil)
+	jsonReq.Header.Set("Accept", "application/json")
+
+	// Act
+	var healthJSON srv.HealthJSONResponse
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, httptest.NewRequest("GET", "/_system/liveness", nil))
+	json.NewDecoder(res.Result().Body).Decode(&healthJSON)
+	jsonRes := httptest.NewRecorder()
+	s.Router.ServeHTTP(jsonRes, jsonReq)
+
+	// Assert
+	assert.Equal(http.StatusInternalServerError, res.Code)
+	assert.Equal(srv.HealthJSONContentType, res.Header().Get("Content-Type"))
+	assert.Equal("fail", healthJSON.Status)
+	assert.Equal("fail", healthJSON.Checks["broken"][0].Status)
+	assert.Equal("not ok", healthJSON.Checks["broken"][0].Output)
+	assert.Equal("application/json; charset=utf-8", jsonRes.Header().Get("Content-Type"))
+}
+
+func TestServer_OnHealthChange(t *testing.T) {
+	t.Run("Notify", testServer_OnHealthChange_Notify)
+	t.Run("History", testServer_OnHealthChange_History)
+}
+
+func testServer_OnHealthChange_Notify(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var healthy int32 = 1
+	var changes []srv.HealthChange
+	s := srv.New()
+	s.AddReadinessCheck("flapping", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: atomic.LoadInt32(&healthy) == 1}
+	})
+	s.OnHealthChange(func(change srv.HealthChange) {
+		changes = append(changes, change)
+	})
+
+	// Act
+	getHealth(s, "/_system/readiness")
+	getHealth(s, "/_system/readiness")
+	atomic.StoreInt32(&healthy, 0)
+	getHealth(s, "/_system/readiness")
+
+	// Assert
+	if assert.Len(changes, 1) {
+		assert.Equal("readiness", changes[0].Endpoint)
+		assert.Equal("flapping", changes[0].Check)
+		assert.Equal("ok", changes[0].From)
+		assert.Equal("not ok", changes[0].To)
+		assert.False(changes[0].Time.IsZero())
+	}
+}
+
+func testServer_OnHealthChange_History(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var calls int32
+	s := srv.New(srv.OptionHealthHistorySize(2))
+	s.AddLivenessCheck("flapping", func(ctx context.Context) srv.HealthMetricResult {
+		return srv.HealthMetricResult{OK: atomic.AddInt32(&calls, 1)%2 == 1}
+	})
+
+	// Act
+	for i := 0; i < 4; i++ {
+		getHealth(s, "/_system/liveness")
+	}
+	var history []srv.HealthChange
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, httptest.NewRequest("GET", "/_system/health/history", nil))
+	json.NewDecoder(res.Result().Body).Decode(&history)
+
+	// Assert
+	assert.Equal(http.StatusOK, res.Code)
+	if assert.Len(history, 2) {
+		assert.Equal("ok", history[0].To)
+		assert.Equal("not ok", history[1].To)
+	}
+	assert.Len(s.HealthHistory(), 2)
+}
+
+func TestServer_Run(t *testing.T) {
+	t.Run("Success", testServer_Run_Success)
+	t.Run("ServerRunningError", testServer_Run_ServerRunningError)
+	t.Run("ListenError", testServer_Run_ListenError)
+	t.Run("UnixSocket", testServer_Run_UnixSocket)
+	t.Run("SocketActivationError", testServer_Run_SocketActivationError)
+}
+
+func testServer_Run_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	errCh := make(chan error)
+	s := srv.New()
+
+	// Act
+	go func() { errCh <- s.Run(defaultAddr) }()
+	waitFor(s.IsRunning)
+	s.Shutdown()
+
+	// Assert
+	assert.Nil(<-errCh)
+}
+
+func testServer_Run_ServerRunningError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	err := s.Start(defaultAddr)
 	defer s.Shutdown()
-	waitForAddr(defaultAddr)
 	assert.Nil(err)
 
 	// Act
-	go func() { err = s.Run(defaultAddr) }()
-	waitForAddr(defaultAddr)
+	err = s.Run(defaultAddr)
 
 	// Assert
-	assert.Equal(err, srv.ErrsrvAlreadyRunning)
+	assert.Equal(err, srv.ErrServerAlreadyRunning)
 }
 
-func testsrv_Run_ListenError(t *testing.T) {
+func testServer_Run_ListenError(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
-
 	s1 := srv.New()
-	go func() { err = s1.Run(defaultAddr) }()
+	err := s1.Start(defaultAddr)
 	defer s1.Shutdown()
-	waitForAddr(defaultAddr)
 	assert.Nil(err)
 
 	// Act
 	s2 := srv.New()
-	go func() { err = s2.Run(defaultAddr) }()
-	defer s2.Shutdown()
-	waitForAddr(defaultAddr)
+	err = s2.Run(defaultAddr)
 
 	// Assert
 	assert.NotNil(err)
-	assert.Equal(err.Error(), "common/srv: failed to start srv: listen tcp "+defaultAddr+": bind: address already in use")
+	assert.Equal(err.Error(), "common/server: failed to start server: listen tcp "+defaultAddr+": bind: address already in use")
+}
+
+func testServer_Run_UnixSocket(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "srv-unix")
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "srv.sock")
+	s := srv.New(srv.OptionUnixSocketMode(0660))
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		},
+	}}
+
+	// Act
+	startErr := s.Start("unix:" + sock)
+	defer s.Shutdown()
+	res, err := client.Get("http://unix/_system/liveness")
+	info, _ := os.Stat(sock)
+
+	// Assert
+	assert.NoError(startErr)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("unix", s.Addr().Network())
+	assert.Equal(os.FileMode(0660), info.Mode().Perm())
+}
+
+func testServer_Run_SocketActivationError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	err := srv.New(srv.OptionSocketActivation()).Run(defaultAddr)
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestServer_Serve(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := srv.New()
+
+	// Act
+	go s.Serve(l)
+	defer s.Shutdown()
+	waitFor(func() bool { return s.Addr() != nil })
+	res, err := http.Get("http://" + s.Addr().String() + "/_system/liveness")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(l.Addr(), s.Addr())
+}
+
+func TestServer_Start(t *testing.T) {
+	t.Run("Success", testServer_Start_Success)
+	t.Run("ListenError", testServer_Start_ListenError)
+}
+
+func testServer_Start_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act
+	err := s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+	res, getErr := http.Get("http://" + s.Addr().String() + "/_system/liveness")
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(getErr)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.True(s.IsRunning())
+}
+
+func testServer_Start_ListenError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+
+	// Act
+	err := srv.New().Start(l.Addr().String())
+
+	// Assert
+	assert.Error(err)
+}
+
+func TestServer_RunContext(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := srv.New()
+	errCh := make(chan error)
+	go func() { errCh <- s.RunContext(ctx, "127.0.0.1:0") }()
+	waitFor(s.IsRunning)
+
+	// Act
+	cancel()
+	err := <-errCh
+
+	// Assert
+	assert.NoError(err)
+	assert.False(s.IsRunning())
+}
+
+func TestServer_Draining(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := srv.New(srv.OptionDrainDelay(300 * time.Millisecond))
+	errCh := make(chan error)
+	go func() { errCh <- s.RunContext(ctx, "127.0.0.1:0") }()
+	waitFor(s.IsRunning)
+	addr := s.Addr().String()
+
+	// Act
+	cancel()
+	waitFor(s.Draining)
+	readiness, readinessErr := http.Get("http://" + addr + "/_system/readiness")
+	liveness, livenessErr := http.Get("http://" + addr + "/_system/liveness")
+	var data srv.HealthResponse
+	json.NewDecoder(readiness.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(readinessErr)
+	assert.NoError(livenessErr)
+	assert.Equal(http.StatusInternalServerError, readiness.StatusCode)
+	assert.Equal("draining", data.Metrics["server"].Status)
+	assert.Equal(http.StatusOK, liveness.StatusCode)
+	assert.NoError(<-errCh)
+	assert.False(s.Draining())
+}
+
+func TestServer_DisableKeepAlivesOnDrain(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := srv.New(srv.OptionDrainDelay(200*time.Millisecond), srv.OptionDisableKeepAlivesOnDrain(true))
+	errCh := make(chan error)
+	go func() { errCh <- s.RunContext(ctx, "127.0.0.1:0") }()
+	waitFor(s.IsRunning)
+	addr := s.Addr().String()
+
+	// Act
+	cancel()
+	waitFor(s.Draining)
+	res, err := http.Get("http://" + addr + "/_system/liveness")
+
+	// Assert
+	assert.NoError(err)
+	assert.True(res.Close)
+	assert.NoError(<-errCh)
+}
+
+func TestServer_HTTPOptions(t *testing.T) {
+	t.Run("ReadHeaderTimeout", testServer_HTTPOptions_ReadHeaderTimeout)
+	t.Run("MaxHeaderBytes", testServer_HTTPOptions_MaxHeaderBytes)
+}
+
+func testServer_HTTPOptions_ReadHeaderTimeout(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionReadHeaderTimeout(50 * time.Millisecond))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+	conn, _ := net.Dial("tcp", s.Addr().String())
+	defer conn.Close()
+
+	// Act
+	conn.Write([]byte("GET /_system/liveness HTTP/1.1\r\nHost: localhost\r\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := conn.Read(make([]byte, 1024))
+
+	// Assert
+	assert.Equal(io.EOF, err)
 }
 
-func Testsrv_IsRunning(t *testing.T) {
-	t.Run("Running", testsrv_IsRunning_Running)
-	t.Run("Stopped", testsrv_IsRunning_Stopped)
+func testServer_HTTPOptions_MaxHeaderBytes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionMaxHeaderBytes(1024))
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+	req, _ := http.NewRequest("GET", "http://"+s.Addr().String()+"/_system/liveness", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+
+	// Act
+	res, err := http.DefaultClient.Do(req)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusRequestHeaderFieldsTooLarge, res.StatusCode)
 }
 
-func testsrv_IsRunning_Running(t *testing.T) {
+func TestServer_Done(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
 	s := srv.New()
+	s.Start("127.0.0.1:0")
+	done := s.Done()
+
+	// Act
+	go s.Shutdown()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail("server did not stop")
+	}
+}
+
+func TestServer_Addr(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	addr := srv.New().Addr()
 
-	go func() { err = s.Run(defaultAddr) }()
+	// Assert
+	assert.Nil(addr)
+}
+
+func TestServer_IsRunning(t *testing.T) {
+	t.Run("Running", testServer_IsRunning_Running)
+	t.Run("Stopped", testServer_IsRunning_Stopped)
+}
+
+func testServer_IsRunning_Running(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	err := s.Start(defaultAddr)
 	defer s.Shutdown()
-	waitForAddr(defaultAddr)
 	assert.Nil(err)
 
 	// Act
@@ -235,7 +830,7 @@ func testsrv_IsRunning_Running(t *testing.T) {
 	assert.True(status)
 }
 
-func testsrv_IsRunning_Stopped(t *testing.T) {
+func testServer_IsRunning_Stopped(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
 
@@ -246,19 +841,18 @@ func testsrv_IsRunning_Stopped(t *testing.T) {
 	assert.False(status)
 }
 
-func Testsrv_Shutdown(t *testing.T) {
-	t.Run("Success", testsrv_Shutdown_Success)
-	t.Run("NotRunning", testsrv_Shutdown_NotRunning)
+func TestServer_Shutdown(t *testing.T) {
+	t.Run("Success", testServer_Shutdown_Success)
+	t.Run("NotRunning", testServer_Shutdown_NotRunning)
+	t.Run("Hooks", testServer_Shutdown_Hooks)
+	t.Run("HookErrors", testServer_Shutdown_HookErrors)
 }
 
-func testsrv_Shutdown_Success(t *testing.T) {
+func testServer_Shutdown_Success(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	var err error
-
 	s := srv.New()
-	go func() { err = s.Run(defaultAddr) }()
-	waitForAddr(defaultAddr)
+	err := s.Start(defaultAddr)
 	assert.Nil(err)
 
 	// Act
@@ -266,9 +860,11 @@ func testsrv_Shutdown_Success(t *testing.T) {
 
 	// Assert
 	assert.Nil(err)
+	assert.Nil(s.Wait())
+	assert.False(s.IsRunning())
 }
 
-func testsrv_Shutdown_NotRunning(t *testing.T) {
+func testServer_Shutdown_NotRunning(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
 
@@ -276,120 +872,381 @@ func testsrv_Shutdown_NotRunning(t *testing.T) {
 	err := srv.New().Shutdown()
 
 	// Assert
-	assert.Equal(err, srv.ErrsrvStopped)
+	assert.Equal(err, srv.ErrServerStopped)
+}
+
+func testServer_Shutdown_Hooks(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	var order []string
+	var deadline time.Time
+	s := srv.New(srv.OptionShutdownTimeout(time.Second))
+	s.OnShutdown("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+	s.OnShutdown("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+	s.Start("127.0.0.1:0")
+
+	// Act
+	err := s.Shutdown()
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal([]string{"second", "first"}, order)
+	assert.WithinDuration(time.Now().Add(time.Second), deadline, time.Second)
 }
 
-func Testsrv_Handle(t *testing.T) {
+func testServer_Shutdown_HookErrors(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	errFirst := errors.New("first failed")
+	errSecond := errors.New("second failed")
+	s := srv.New()
+	s.OnShutdown("first", func(ctx context.Context) error { return errFirst })
+	s.OnShutdown("second", func(ctx context.Context) error { return errSecond })
+	s.Start("127.0.0.1:0")
+
+	// Act
+	err := s.Shutdown()
+
+	// Assert
+	assert.True(errors.Is(err, errFirst))
+	assert.True(errors.Is(err, errSecond))
+	assert.False(s.IsRunning())
+}
+
+func TestServer_Metrics(t *testing.T) {
+	t.Run("TextFormat", testServer_Metrics_TextFormat)
+	t.Run("OpenMetrics", testServer_Metrics_OpenMetrics)
+	t.Run("Routes", testServer_Metrics_Routes)
+	t.Run("RouteDurations", testServer_Metrics_RouteDurations)
+	t.Run("RouteInfo", testServer_Metrics_RouteInfo)
+	t.Run("HealthChecks", testServer_Metrics_HealthChecks)
+	t.Run("InfoMetrics", testServer_Metrics_InfoMetrics)
+	t.Run("UniqueFamilies", testServer_Metrics_UniqueFamilies)
+}
+
+func testServer_Metrics_TextFormat(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
 
 	// Act
-	srv.Handle("GET", "/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	res, body := getMetrics(s, "")
 
 	// Assert
-	handler, _, _ := srv.Lookup("GET", "/testpath")
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal("text/plain; version=0.0.4; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(body, "# TYPE go_goroutines gauge\ngo_goroutines ")
+	assert.Contains(body, "# TYPE go_memstats_alloc_bytes_total counter\ngo_memstats_alloc_bytes_total ")
+	assert.Contains(body, "\nprocess_start_time_seconds ")
+	assert.NotContains(body, "# EOF")
+}
+
+func testServer_Metrics_OpenMetrics(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act
+	res, body := getMetrics(s, "application/openmetrics-text; version=1.0.0")
+
+	// Assert
+	assert.Equal("application/openmetrics-text; version=1.0.0; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(body, "# TYPE go_memstats_alloc_bytes_cumulative counter\ngo_memstats_alloc_bytes_cumulative_total ")
+	assert.True(strings.HasSuffix(body, "# EOF\n"))
+}
+
+func testServer_Metrics_UniqueFamilies(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.AddReadinessCheck("database", func(ctx context.Context) srv.HealthMetricResult { return srv.HealthMetricResult{OK: true} })
+	s.AddInfoMetric("queueSize", func() interface{} { return 3 })
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/_system/readiness", nil))
+
+	for _, accept := range []string{"text/plain", "application/openmetrics-text; version=1.0.0"} {
+		// Act
+		_, body := getMetrics(s, accept)
+
+		// Assert
+		families := map[string]int{}
+		for _, line := range strings.Split(body, "\n") {
+			if fields := strings.Fields(line); len(fields) == 4 && fields[0] == "#" && fields[1] == "TYPE" {
+				families[fields[2]]++
+			}
+		}
+		assert.NotEmpty(families)
+		for name, count := range families {
+			assert.Equal(1, count, "%s family %s", accept, name)
+		}
+	}
+}
+
+func testServer_Metrics_Routes(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.POST("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	s.DELETE("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("failed")
+	})
+
+	// Act
+	for _, path := range []string{"/users/1", "/users/2"} {
+		s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users/1", nil))
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/users/1", nil))
+	_, body := getMetrics(s, "")
+
+	// Assert
+	assert.Contains(body, "# TYPE srv_http_requests_total counter\n")
+	assert.Contains(body, `srv_http_requests_total{method="GET",route="/users/:id",code="200"} 2`)
+	assert.Contains(body, `srv_http_requests_total{method="POST",route="/users/:id",code="201"} 1`)
+	assert.Contains(body, `srv_http_requests_total{method="DELETE",route="/users/:id",code="500"} 1`)
+	assert.NotContains(body, "/users/1")
+}
+
+func testServer_Metrics_RouteDurations(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New(srv.OptionRequestDurationBuckets(1, 0.01))
+	s.GET("/slow", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	s.GET("/missing", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	// Act
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	_, body := getMetrics(s, "")
+
+	// Assert
+	assert.Contains(body, "# TYPE srv_http_request_duration_seconds histogram\n")
+	assert.Contains(body, `srv_http_request_duration_seconds_bucket{method="GET",route="/slow",class="2xx",le="0.01"} 0`)
+	assert.Contains(body, `srv_http_request_duration_seconds_bucket{method="GET",route="/slow",class="2xx",le="1"} 1`)
+	assert.Contains(body, `srv_http_request_duration_seconds_bucket{method="GET",route="/slow",class="2xx",le="+Inf"} 1`)
+	assert.Contains(body, `srv_http_request_duration_seconds_count{method="GET",route="/slow",class="2xx"} 1`)
+	assert.Contains(body, `srv_http_request_duration_seconds_bucket{method="GET",route="/missing",class="4xx",le="0.01"} 1`)
+}
+
+func testServer_Metrics_RouteInfo(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if ps.ByName("id") == "0" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	// Act
+	for _, path := range []string{"/users/0", "/users/1", "/users/2"} {
+		s.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, httptest.NewRequest("GET", "/_system/info", nil))
+	var info srv.InfoResponse
+	decodeJres(res.Code, res.Body, &info)
+
+	// Assert
+	if assert.Len(info.Routes, 1) {
+		assert.Equal("GET", info.Routes[0].Method)
+		assert.Equal("/users/:id", info.Routes[0].Path)
+		assert.Equal(uint64(3), info.Routes[0].Requests)
+		assert.Equal(uint64(1), info.Routes[0].Errors)
+		assert.Equal(map[string]uint64{"2xx": 2, "5xx": 1}, info.Routes[0].Statuses)
+		assert.NotEmpty(info.Routes[0].Latency)
+	}
+}
+
+func testServer_Metrics_HealthChecks(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.AddReadinessCheck("db", func(ctx context.Context) srv.HealthMetricResult { return srv.HealthMetricResult{OK: true} })
+	s.AddLivenessCheck("cache", func(ctx context.Context) srv.HealthMetricResult { return srv.HealthMetricResult{OK: false} })
+
+	// Act
+	_, before := getMetrics(s, "")
+	getHealth(s, "/_system/readiness")
+	getHealth(s, "/_system/liveness")
+	_, body := getMetrics(s, "")
+
+	// Assert
+	assert.NotContains(before, "srv_health_check_status")
+	assert.Contains(body, `srv_health_check_status{endpoint="readiness",check="db",status="pass"} 1`)
+	assert.Contains(body, `srv_health_check_status{endpoint="readiness",check="db",status="fail"} 0`)
+	assert.Contains(body, `srv_health_check_status{endpoint="liveness",check="cache",status="fail"} 1`)
+	assert.Contains(body, `srv_health_check_last_transition_timestamp_seconds{endpoint="liveness",check="cache"} `)
+}
+
+func testServer_Metrics_InfoMetrics(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.AddInfoMetric("queueDepth", func() interface{} { return 12 })
+	s.AddInfoMetric("version", func() interface{} { return "1.2.3" })
+
+	// Act
+	_, body := getMetrics(s, "")
+
+	// Assert
+	assert.Contains(body, "# TYPE srv_info_queue_depth gauge\nsrv_info_queue_depth 12\n")
+	assert.NotContains(body, "srv_info_version")
+}
+
+func TestServer_Handle(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+
+	// Act
+	s.Handle("GET", "/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+
+	// Assert
+	handler, _, _ := s.Lookup("GET", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_GET(t *testing.T) {
+func TestServer_GET(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.GET("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.GET("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("GET", "/testpath")
+	handler, _, _ := s.Lookup("GET", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_POST(t *testing.T) {
+func TestServer_POST(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.POST("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.POST("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("POST", "/testpath")
+	handler, _, _ := s.Lookup("POST", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_PUT(t *testing.T) {
+func TestServer_PUT(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.PUT("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.PUT("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("PUT", "/testpath")
+	handler, _, _ := s.Lookup("PUT", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_PATCH(t *testing.T) {
+func TestServer_PATCH(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.PATCH("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.PATCH("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("PATCH", "/testpath")
+	handler, _, _ := s.Lookup("PATCH", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_DELETE(t *testing.T) {
+func TestServer_DELETE(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.DELETE("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.DELETE("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("DELETE", "/testpath")
+	handler, _, _ := s.Lookup("DELETE", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_HEAD(t *testing.T) {
+func TestServer_HEAD(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.HEAD("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.HEAD("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("HEAD", "/testpath")
+	handler, _, _ := s.Lookup("HEAD", "/testpath")
 	assert.NotNil(handler)
 }
 
-func Testsrv_OPTIONS(t *testing.T) {
+func TestServer_OPTIONS(t *testing.T) {
 	// Arrange
 	assert := assert.New(t)
-	srv := srv.New()
+	s := srv.New()
 
 	// Act
-	srv.OPTIONS("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.OPTIONS("/testpath", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
 
 	// Assert
-	handler, _, _ := srv.Lookup("OPTIONS", "/testpath")
+	handler, _, _ := s.Lookup("OPTIONS", "/testpath")
 	assert.NotNil(handler)
 }
 
-func waitForAddr(addr string) {
-	port := strings.Split(addr, ":")[1]
-	for i := 1; i <= 10; i++ {
-		conn, _ := net.DialTimeout("tcp", net.JoinHostPort("", port), 100*time.Millisecond)
-		if conn != nil {
-			conn.Close()
-			break
-		}
+// getHealth requests the health endpoint at path from the server router
+func getHealth(s *srv.Server, path string) (*httptest.ResponseRecorder, srv.HealthResponse) {
+	var parsedRes srv.HealthResponse
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+	decodeJres(res.Code, res.Body, &parsedRes)
+
+	return res, parsedRes
+}
+
+// decodeJres decodes the body of a jres response, an ok response has its value in the data field
+func decodeJres(status int, body io.Reader, v interface{}) error {
+	if status != http.StatusOK {
+		return json.NewDecoder(body).Decode(v)
+	}
+
+	return json.NewDecoder(body).Decode(&struct {
+		Data interface{} `json:"data"`
+	}{Data: v})
+}
+
+// getMetrics returns the metrics endpoint response accepting the given format
+func getMetrics(s *srv.Server, accept string) (*httptest.ResponseRecorder, string) {
+	req := httptest.NewRequest("GET", "/_system/metrics", nil)
+	req.Header.Set("Accept", accept)
+	res := httptest.NewRecorder()
+	s.Router.ServeHTTP(res, req)
+
+	return res, res.Body.String()
+}
+
+// waitFor polls until the condition is met or gives up after a second
+func waitFor(ready func() bool) {
+	for i := 1; i <= 100 && !ready(); i++ {
+		time.Sleep(10 * time.Millisecond)
 	}
 }
@@ -0,0 +1,76 @@
+//go:build !windows
+// +build !windows
+
+package srv_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_RunSignals(t *testing.T) {
+	t.Run("StopSignalSuccess", testServer_RunSignals_StopSignalSuccess)
+	t.Run("CustomStopSignal", testServer_RunSignals_CustomStopSignal)
+	t.Run("NoStopSignals", testServer_RunSignals_NoStopSignals)
+}
+
+func testServer_RunSignals_StopSignalSuccess(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	errCh := make(chan error)
+	s := srv.New()
+
+	go func() { errCh <- s.Run(defaultAddr) }()
+	waitFor(s.IsRunning)
+
+	// Act
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	err := <-errCh
+
+	// Assert
+	assert.Nil(err)
+	assert.False(s.IsRunning())
+}
+
+func testServer_RunSignals_CustomStopSignal(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	errCh := make(chan error)
+	s := srv.New(srv.OptionStopSignals(syscall.SIGUSR1))
+
+	go func() { errCh <- s.Run(defaultAddr) }()
+	waitFor(s.IsRunning)
+
+	// Act
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	err := <-errCh
+
+	// Assert
+	assert.Nil(err)
+	assert.False(s.IsRunning())
+}
+
+func testServer_RunSignals_NoStopSignals(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	errCh := make(chan error)
+	s := srv.New(srv.OptionStopSignals())
+
+	go func() { errCh <- s.Run(defaultAddr) }()
+	waitFor(s.IsRunning)
+
+	// Act
+	syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+	time.Sleep(50 * time.Millisecond)
+	running := s.IsRunning()
+	s.Shutdown()
+
+	// Assert
+	assert.True(running)
+	assert.Nil(<-errCh)
+}
@@ -0,0 +1,66 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// AddStartupCheck to the list of startup metrics used to validate the system has
+// finished booting at the /_system/startup endpoint. The name parameter should be
+// camel-case. Startup metrics only need to pass once, after which the startup endpoint
+// reports ok without running them again. The readiness endpoint is not ok until then.
+func (s *Server) AddStartupCheck(name string, handler HealthMetricHandler, opts ...HealthCheckOption) {
+	s.startupMetrics.add(newHealthMetric(name, handler, opts))
+}
+
+// Started tells if all of the startup checks have passed
+func (s *Server) Started() bool {
+	return atomic.LoadInt32(&s.started) == 1
+}
+
+// checkStartup runs the startup checks until all of them have passed once, a non-critical
+// check that is degraded has not passed yet
+func (s *Server) checkStartup(ctx context.Context) (map[string]HealthMetricResult, bool) {
+	if s.Started() {
+		return nil, true
+	}
+
+	metrics, state := checkHealth(ctx, s.startupMetrics.list(), s.healthCheckTimeout)
+	isOk := state == healthPass
+	if isOk {
+		atomic.StoreInt32(&s.started, 1)
+	}
+
+	return metrics, isOk
+}
+
+// startupHandler reports "starting" with the result of the startup checks until they
+// have all passed once and ok from then on
+func (s *Server) startupHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	metrics, isOk := s.checkStartup(r.Context())
+	if !isOk {
+		sendHealth(w, r, s.healthContentType, healthFail, HealthResponse{Status: "starting", Metrics: metrics})
+		return
+	}
+
+	sendHealth(w, r, s.healthContentType, healthPass, HealthResponse{Status: "ok", Metrics: metrics})
+}
+
+// startupAwareHandler reports the readiness as not ok with a "starting" status until
+// the startup checks have passed and otherwise calls the next handler
+func (s *Server) startupAwareHandler(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, isOk := s.checkStartup(r.Context()); isOk {
+			next(w, r, ps)
+			return
+		}
+
+		sendHealth(w, r, s.healthContentType, healthFail, HealthResponse{
+			Status:  "not ok",
+			Metrics: map[string]HealthMetricResult{"startup": {Status: "starting"}},
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package srv
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTLSReloadInterval is how often the certificate files are checked for changes
+const defaultTLSReloadInterval = time.Minute
+
+// defaultTLSExpiryThreshold is how close to expiring the certificate can get
+// before the readiness check starts failing
+const defaultTLSExpiryThreshold = 24 * time.Hour
+
+// ErrTLSNotConfigured is the error returned when RunTLS is called without a certificate
+var ErrTLSNotConfigured = errors.New("common/server: tls not configured")
+
+// tlsOptions holds the TLS settings collected from the server options
+type tlsOptions struct {
+	certFile     string
+	keyFile      string
+	config       *tls.Config
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	reloadInterval  time.Duration
+	expiryThreshold time.Duration
+	reloader        *certReloader
+}
+
+// buildTLSConfig creates the tls.Config used by RunTLS from the server options.
+// The in-memory config is cloned so the caller's copy is never modified.
+func (s *Server) buildTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.tls.config != nil {
+		cfg = s.tls.config.Clone()
+	}
+
+	if s.tls.certFile != "" || s.tls.keyFile != "" {
+		if s.tls.reloader == nil {
+			s.tls.reloader = &certReloader{certFile: s.tls.certFile, keyFile: s.tls.keyFile, logger: s.logger}
+			s.AddInfoMetric("tlsCertificate", s.tls.reloader.infoMetric)
+			s.AddReadinessCheck("tlsCertificate", s.tls.reloader.healthCheck(s.tls.expiryThreshold))
+		}
+
+		if err := s.tls.reloader.reload(); err != nil {
+			return nil, err
+		}
+		cfg.GetCertificate = chainCertificate(s.tls.reloader, cfg.GetCertificate, cfg.Certificates)
+	}
+
+	if len(cfg.Certificates) == 0 && cfg.GetCertificate == nil {
+		return nil, ErrTLSNotConfigured
+	}
+
+	if s.tls.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(s.tls.clientCAFile)
+		if err != nil {
+			return nil, errors.New("common/server: failed to load client ca: " + err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("common/server: failed to load client ca: no certificates found")
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if s.tls.clientAuth != tls.NoClientCert {
+		cfg.ClientAuth = s.tls.clientAuth
+	}
+
+	return cfg, nil
+}
+
+// ReloadTLS reloads the certificate and key files configured with OptionTLSCertFiles.
+// New TLS connections are served with the new certificate while the existing connections
+// are left untouched. The current certificate is kept if the files fail to load.
+func (s *Server) ReloadTLS() error {
+	if s.tls.reloader == nil {
+		return ErrTLSNotConfigured
+	}
+
+	return s.tls.reloader.reload()
+}
+
+// PeerIdentity is the identity of a client that connected with a verified certificate
+type PeerIdentity struct {
+	CommonName   string            `json:"commonName"`
+	Organization []string          `json:"organization,omitempty"`
+	DNSNames     []string          `json:"dnsNames,omitempty"`
+	EmailAddrs   []string          `json:"emailAddresses,omitempty"`
+	URIs         []string          `json:"uris,omitempty"`
+	Certificate  *x509.Certificate `json:"-"`
+}
+
+// PeerIdentityFromRequest returns the identity of the client certificate verified
+// during the TLS handshake. The second return value is false when the request was
+// not made over TLS or the client did not present a verified certificate.
+func PeerIdentityFromRequest(r *http.Request) (PeerIdentity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return PeerIdentity{}, false
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	id := PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		EmailAddrs:   cert.EmailAddresses,
+		Certificate:  cert,
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+
+	return id, true
+}
+
+// certReloader serves the certificate loaded from the cert and key files and
+// atomically swaps it when the files change or a reload is requested
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	cert    atomic.Value // *tls.Certificate
+	modTime time.Time
+	mu      sync.Mutex
+	stop    chan struct{}
+}
+
+// reload the certificate from disk, the current certificate is kept on failure
+func (c *certReloader) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.load()
+}
+
+// load the certificate and key files, the caller must hold the lock
+func (c *certReloader) load() error {
+	modTime := c.filesModTime()
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return errors.New("common/server: failed to load certificate: " + err.Error())
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return errors.New("common/server: failed to parse certificate: " + err.Error())
+		}
+	}
+
+	c.cert.Store(&cert)
+	c.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the latest modification time of the cert and key files
+func (c *certReloader) filesModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// certificate returns the certificate currently being served
+func (c *certReloader) certificate() *tls.Certificate {
+	cert, _ := c.cert.Load().(*tls.Certificate)
+	return cert
+}
+
+// getCertificate is used as the tls.Config GetCertificate func
+func (c *certReloader) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := c.certificate(); cert != nil {
+		return cert, nil
+	}
+
+	return nil, ErrTLSNotConfigured
+}
+
+// chainCertificate returns the GetCertificate func serving the reloaded certificate when it suits
+// the ClientHello and otherwise the certificate from the GetCertificate or Certificates of the
+// caller's config, the reloaded certificate is served when none of them suit the ClientHello
+func chainCertificate(reloader *certReloader, next func(*tls.ClientHelloInfo) (*tls.Certificate, error), certs []tls.Certificate) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if next == nil && len(certs) == 0 {
+		return reloader.getCertificate
+	}
+
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := reloader.getCertificate(hello)
+		if err != nil || hello.SupportsCertificate(cert) == nil {
+			return cert, err
+		}
+
+		if next != nil {
+			if nextCert, err := next(hello); nextCert != nil || err != nil {
+				return nextCert, err
+			}
+		}
+
+		for i := range certs {
+			if hello.SupportsCertificate(&certs[i]) == nil {
+				return &certs[i], nil
+			}
+		}
+
+		return cert, nil
+	}
+}
+
+// watch the certificate files for changes on the interval provided until stopWatching is called
+func (c *certReloader) watch(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if interval <= 0 || c.stop != nil {
+		return
+	}
+
+	c.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				if c.filesModTime().After(c.modTime) {
+					if err := c.load(); err != nil {
+						loggerOrDefault(c.logger).Error("Failed to reload TLS certificate", "error", err)
+					} else {
+						loggerOrDefault(c.logger).Info("Reloaded TLS certificate")
+					}
+				}
+				c.mu.Unlock()
+			}
+		}
+	}(c.stop)
+}
+
+// stopWatching the certificate files for changes
+func (c *certReloader) stopWatching() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// infoMetric reports the expiry of the certificate currently being served
+func (c *certReloader) infoMetric() interface{} {
+	cert := c.certificate()
+	if cert == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"subject":   cert.Leaf.Subject.CommonName,
+		"notAfter":  cert.Leaf.NotAfter.Format(time.RFC3339),
+		"expiresIn": time.Until(cert.Leaf.NotAfter).Round(time.Second).String(),
+	}
+}
+
+// healthCheck fails when the certificate being served expires within the threshold
+func (c *certReloader) healthCheck(threshold time.Duration) HealthMetricHandler {
+	return func(ctx context.Context) HealthMetricResult {
+		cert := c.certificate()
+		if cert == nil {
+			return HealthMetricResult{OK: false, Status: "not loaded"}
+		}
+
+		expiresIn := time.Until(cert.Leaf.NotAfter)
+		info := map[string]interface{}{"expiresIn": expiresIn.Round(time.Second).String()}
+		if expiresIn < threshold {
+			return HealthMetricResult{OK: false, Status: "expiring", Info: info}
+		}
+
+		return HealthMetricResult{OK: true, Info: info}
+	}
+}
@@ -0,0 +1,382 @@
+package srv_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+const tlsAddr = ":9877"
+
+func TestServer_RunTLS(t *testing.T) {
+	t.Run("Success", testServer_RunTLS_Success)
+	t.Run("MutualTLS", testServer_RunTLS_MutualTLS)
+	t.Run("MutualTLSNoClientCert", testServer_RunTLS_MutualTLSNoClientCert)
+	t.Run("NotConfigured", testServer_RunTLS_NotConfigured)
+	t.Run("BadCertFiles", testServer_RunTLS_BadCertFiles)
+	t.Run("ConfigCertificates", testServer_RunTLS_ConfigCertificates)
+	t.Run("ConfigGetCertificate", testServer_RunTLS_ConfigGetCertificate)
+}
+
+func testServer_RunTLS_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	s := srv.New(srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey))
+
+	// Act
+	err := s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+	res, getErr := certs.client(false).Get("https://localhost" + tlsAddr + "/_system/liveness")
+
+	// Assert
+	assert.Nil(err)
+	assert.NoError(getErr)
+	assert.Equal(http.StatusOK, res.StatusCode)
+}
+
+func testServer_RunTLS_MutualTLS(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	s := srv.New(
+		srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey),
+		srv.OptionTLSClientCAFile(certs.caCert),
+	)
+	var peer srv.PeerIdentity
+	var ok bool
+	s.GET("/whoami", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		peer, ok = srv.PeerIdentityFromRequest(r)
+	})
+
+	// Act
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+	res, err := certs.client(true).Get("https://localhost" + tlsAddr + "/whoami")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.True(ok)
+	assert.Equal("test-client", peer.CommonName)
+}
+
+func testServer_RunTLS_MutualTLSNoClientCert(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	s := srv.New(
+		srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey),
+		srv.OptionTLSClientCAFile(certs.caCert),
+	)
+
+	// Act
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+	_, err := certs.client(false).Get("https://localhost" + tlsAddr + "/_system/liveness")
+
+	// Assert
+	assert.Error(err)
+}
+
+func testServer_RunTLS_NotConfigured(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	err := srv.New().RunTLS(tlsAddr)
+
+	// Assert
+	assert.Equal(srv.ErrTLSNotConfigured, err)
+}
+
+func testServer_RunTLS_BadCertFiles(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	err := srv.New(srv.OptionTLSCertFiles("missing.crt", "missing.key")).RunTLS(tlsAddr)
+
+	// Assert
+	assert.Error(err)
+}
+
+func testServer_RunTLS_ConfigCertificates(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	other := certs.keyPair(t, "other.test")
+	s := srv.New(
+		srv.OptionTLSConfig(&tls.Config{Certificates: []tls.Certificate{other}}),
+		srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey),
+	)
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+
+	// Act
+	otherName := servedName(t, certs, "other.test")
+	localName := servedName(t, certs, "localhost")
+
+	// Assert
+	assert.Equal("other.test", otherName)
+	assert.Equal("test-server", localName)
+}
+
+func testServer_RunTLS_ConfigGetCertificate(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	other := certs.keyPair(t, "other.test")
+	s := srv.New(
+		srv.OptionTLSConfig(&tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "other.test" {
+				return &other, nil
+			}
+			return nil, nil
+		}}),
+		srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey),
+	)
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+
+	// Act
+	otherName := servedName(t, certs, "other.test")
+	localName := servedName(t, certs, "localhost")
+
+	// Assert
+	assert.Equal("other.test", otherName)
+	assert.Equal("test-server", localName)
+}
+
+func TestServer_ReloadTLS(t *testing.T) {
+	t.Run("Success", testServer_ReloadTLS_Success)
+	t.Run("WatchFiles", testServer_ReloadTLS_WatchFiles)
+	t.Run("NotConfigured", testServer_ReloadTLS_NotConfigured)
+	t.Run("ExpiringReadiness", testServer_ReloadTLS_ExpiringReadiness)
+}
+
+func testServer_ReloadTLS_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, 48*time.Hour)
+	s := srv.New(srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey), srv.OptionTLSReloadInterval(0))
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+	before := servedSerial(t, certs)
+	certs.issue(t, "server", 48*time.Hour, x509.ExtKeyUsageServerAuth)
+
+	// Act
+	err := s.ReloadTLS()
+
+	// Assert
+	assert.NoError(err)
+	assert.NotEqual(before, servedSerial(t, certs))
+}
+
+func testServer_ReloadTLS_WatchFiles(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, 48*time.Hour)
+	s := srv.New(srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey), srv.OptionTLSReloadInterval(10*time.Millisecond))
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+	before := servedSerial(t, certs)
+
+	// Act
+	time.Sleep(20 * time.Millisecond)
+	certs.issue(t, "server", 48*time.Hour, x509.ExtKeyUsageServerAuth)
+
+	// Assert
+	after := before
+	for i := 0; i < 50 && after == before; i++ {
+		time.Sleep(10 * time.Millisecond)
+		after = servedSerial(t, certs)
+	}
+	assert.NotEqual(before, after)
+}
+
+func testServer_ReloadTLS_NotConfigured(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	err := srv.New().ReloadTLS()
+
+	// Assert
+	assert.Equal(srv.ErrTLSNotConfigured, err)
+}
+
+func testServer_ReloadTLS_ExpiringReadiness(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	certs := newTestCerts(t, time.Hour)
+	s := srv.New(srv.OptionTLSCertFiles(certs.serverCert, certs.serverKey), srv.OptionTLSExpiryThreshold(2*time.Hour))
+	s.StartTLS(tlsAddr)
+	defer s.Shutdown()
+
+	// Act
+	res, err := certs.client(false).Get("https://localhost" + tlsAddr + "/_system/readiness")
+	var data srv.HealthResponse
+	json.NewDecoder(res.Body).Decode(&data)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Equal("expiring", data.Metrics["tlsCertificate"].Status)
+}
+
+func TestPeerIdentityFromRequest(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+
+	// Act
+	_, ok := srv.PeerIdentityFromRequest(req)
+
+	// Assert
+	assert.False(ok)
+}
+
+// testCerts contains the paths to a generated CA, server and client certificate
+type testCerts struct {
+	dir        string
+	caCert     string
+	serverCert string
+	serverKey  string
+	clientCert string
+	clientKey  string
+
+	caPool *x509.CertPool
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+}
+
+func newTestCerts(t *testing.T, validFor time.Duration) *testCerts {
+	dir, err := ioutil.TempDir("", "srv-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	c := &testCerts{dir: dir, caPool: x509.NewCertPool()}
+	c.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &c.caKey.PublicKey, c.caKey)
+	c.ca, _ = x509.ParseCertificate(caDER)
+	c.caPool.AddCert(c.ca)
+	c.caCert = writePEM(t, dir, "ca.crt", "CERTIFICATE", caDER)
+
+	c.serverCert, c.serverKey = c.issue(t, "server", validFor, x509.ExtKeyUsageServerAuth)
+	c.clientCert, c.clientKey = c.issue(t, "client", validFor, x509.ExtKeyUsageClientAuth)
+
+	return c
+}
+
+// issue a certificate signed by the test CA and write it to disk
+func (c *testCerts) issue(t *testing.T, name string, validFor time.Duration, usage x509.ExtKeyUsage) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test-" + name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.ca, &key.PublicKey, c.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return writePEM(t, c.dir, name+".crt", "CERTIFICATE", der), writePEM(t, c.dir, name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+// keyPair returns an in-memory server certificate signed by the test CA for the DNS name
+func (c *testCerts) keyPair(t *testing.T, dnsName string) tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.ca, &key.PublicKey, c.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// client returns an HTTP client trusting the test CA, optionally presenting the client certificate
+func (c *testCerts) client(withCert bool) *http.Client {
+	cfg := &tls.Config{RootCAs: c.caPool}
+	if withCert {
+		cert, _ := tls.LoadX509KeyPair(c.clientCert, c.clientKey)
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+}
+
+// servedSerial returns the serial number of the certificate the server presents on a new connection
+func servedSerial(t *testing.T, c *testCerts) string {
+	conn, err := tls.Dial("tcp", "localhost"+tlsAddr, &tls.Config{RootCAs: c.caPool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+}
+
+// servedName returns the common name of the certificate the server presents for the server name
+func servedName(t *testing.T, c *testCerts, serverName string) string {
+	conn, err := tls.Dial("tcp", "localhost"+tlsAddr, &tls.Config{RootCAs: c.caPool, ServerName: serverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
@@ -0,0 +1,320 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/urfave/negroni"
+)
+
+const (
+	// traceparentHeader is the W3C trace context header identifying the trace and parent span
+	traceparentHeader = "traceparent"
+	// tracestateHeader is the W3C trace context header carrying vendor specific trace data
+	tracestateHeader = "tracestate"
+)
+
+// TraceID is the identifier of a trace shared by all of its spans
+type TraceID [16]byte
+
+// String returns the trace id as lowercase hex
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID is the identifier of a single span in a trace
+type SpanID [8]byte
+
+// String returns the span id as lowercase hex
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span and carries the trace context propagated with the
+// W3C traceparent and tracestate headers
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool   // if true the span is recorded and exported
+	TraceState string // the tracestate header passed on unchanged
+	Remote     bool   // if true the span context was received from another service
+}
+
+// IsValid tells if the span context has both a trace id and a span id
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// traceparent returns the span context in the W3C traceparent format
+func (sc SpanContext) traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseTraceparent parses the W3C traceparent header. Versions after 00 are parsed
+// by their version 00 fields as the specification asks.
+func parseTraceparent(value string) (SpanContext, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || (len(value) > 55 && (value[:2] == "00" || value[55] != '-')) {
+		return SpanContext{}, false
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' || value[:2] == "ff" || value != strings.ToLower(value) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(value[:2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return SpanContext{}, false
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+	sc.Remote = true
+
+	return sc, sc.IsValid()
+}
+
+// SpanStatusCode is the status of a finished span
+type SpanStatusCode int
+
+const (
+	// SpanStatusUnset is the status of a span that completed without an error
+	SpanStatusUnset SpanStatusCode = iota
+	// SpanStatusOK is the status of a span that was marked as successful
+	SpanStatusOK
+	// SpanStatusError is the status of a span that failed, a 5xx response or a panic
+	SpanStatusError
+)
+
+// SpanEvent is something that happened at a point in time during a span such as a panic
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// Span is a finished server span passed to the SpanExporter
+type Span struct {
+	Name        string // the method and route template such as "GET /users/:id"
+	SpanContext SpanContext
+	Parent      SpanContext // the span context from the traceparent header, not valid for a new trace
+	StartTime   time.Time
+	EndTime     time.Time
+
+	Attributes map[string]interface{} // the OpenTelemetry HTTP attributes such as "http.route"
+	Events     []SpanEvent
+
+	StatusCode    SpanStatusCode
+	StatusMessage string
+}
+
+// SpanExporter sends the finished spans to a tracing backend. ExportSpans is called as
+// each request ends so exporters that send spans over the network should batch them.
+type SpanExporter interface {
+	// ExportSpans exports the spans or queues them to be exported
+	ExportSpans(ctx context.Context, spans []Span) error
+	// Shutdown exports any queued spans, it is called when the server shuts down
+	Shutdown(ctx context.Context) error
+}
+
+// spanKey is the context key of the span recording the request
+type spanKey struct{}
+
+// recordingSpan is the server span of a request that is still in progress
+type recordingSpan struct {
+	mu       sync.Mutex
+	span     Span
+	panicked bool
+}
+
+// setRoute names the span after the route template that matched the request
+func (r *recordingSpan) setRoute(method, route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.span.Name = method + " " + route
+	r.span.Attributes["http.route"] = route
+}
+
+// recordPanic adds the panic as an exception event and fails the span
+func (r *recordingSpan) recordPanic(err interface{}, stack []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.panicked {
+		return
+	}
+
+	r.panicked = true
+	r.span.StatusCode = SpanStatusError
+	r.span.StatusMessage = fmt.Sprint(err)
+	r.span.Events = append(r.span.Events, SpanEvent{Name: "exception", Time: time.Now(), Attributes: map[string]interface{}{
+		"exception.message":    fmt.Sprint(err),
+		"exception.stacktrace": string(stack),
+	}})
+}
+
+// end finishes the span with the status code of the response
+func (r *recordingSpan) end(status int) Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.span.EndTime = time.Now()
+	r.span.Attributes["http.response.status_code"] = status
+	if status >= http.StatusInternalServerError && r.span.StatusCode == SpanStatusUnset {
+		r.span.StatusCode = SpanStatusError
+	}
+
+	return r.span
+}
+
+// SpanContextFromContext returns the span context of the server span recording the request,
+// the span context is not valid when tracing is not enabled
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*recordingSpan); ok {
+		return span.span.SpanContext
+	}
+
+	return SpanContext{}
+}
+
+// InjectTraceContext sets the W3C traceparent and tracestate headers of an outgoing request
+// so the service called joins the trace of the request being served
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(traceparentHeader, sc.traceparent())
+	if sc.TraceState != "" {
+		header.Set(tracestateHeader, sc.TraceState)
+	}
+}
+
+// tracer records a server span for each request and sends it to the exporter
+type tracer struct {
+	exporter SpanExporter
+	logger   *slog.Logger
+}
+
+// ServeHTTP is the middleware starting the server span of the request. The span joins the trace
+// of the traceparent header when it is valid and the trace context is returned in the response.
+func (t *tracer) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	parent, ok := parseTraceparent(r.Header.Get(traceparentHeader))
+
+	sc := SpanContext{SpanID: newSpanID(), Sampled: true}
+	if ok {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = strings.Join(r.Header.Values(tracestateHeader), ",")
+	} else {
+		sc.TraceID = newTraceID()
+	}
+
+	span := &recordingSpan{span: Span{
+		Name:        r.Method,
+		SpanContext: sc,
+		Parent:      parent,
+		StartTime:   time.Now(),
+		Attributes: map[string]interface{}{
+			"http.request.method": r.Method,
+			"url.path":            r.URL.Path,
+			"server.address":      r.Host,
+			"user_agent.original": r.UserAgent(),
+		},
+	}}
+
+	w.Header().Set(traceparentHeader, sc.traceparent())
+	if sc.TraceState != "" {
+		w.Header().Set(tracestateHeader, sc.TraceState)
+	}
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			span.recordPanic(err, debug.Stack())
+			t.export(span.end(http.StatusInternalServerError))
+			panic(err)
+		}
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		t.export(span.end(status))
+	}()
+
+	next(rw, r.WithContext(context.WithValue(r.Context(), spanKey{}, span)))
+}
+
+// export sends the span to the exporter when it is sampled
+func (t *tracer) export(span Span) {
+	if !span.SpanContext.Sampled {
+		return
+	}
+
+	if err := t.exporter.ExportSpans(context.Background(), []Span{span}); err != nil {
+		loggerOrDefault(t.logger).Error("Failed to export span", "span", span.Name, "error", err)
+	}
+}
+
+// traceRoute wraps the handle to name the span of the request after the route template
+// and to record a panic before it is passed on to the panic handler
+func traceRoute(method, route string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		span, ok := r.Context().Value(spanKey{}).(*recordingSpan)
+		if !ok {
+			handle(w, r, ps)
+			return
+		}
+
+		span.setRoute(method, route)
+		defer func() {
+			if err := recover(); err != nil {
+				span.recordPanic(err, debug.Stack())
+				panic(err)
+			}
+		}()
+
+		handle(w, r, ps)
+	}
+}
+
+// newTraceID returns a random trace id
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+// newSpanID returns a random span id
+func newSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return id
+}
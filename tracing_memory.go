@@ -0,0 +1,47 @@
+package srv
+
+import (
+	"context"
+	"sync"
+)
+
+// InMemoryExporter keeps the exported spans in memory, it is meant for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// NewInMemoryExporter creates an exporter that keeps the spans in memory
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpans adds the spans to the ones exported
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Shutdown does nothing as the spans are kept until Reset is called
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the spans exported in the order they finished
+func (e *InMemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Span{}, e.spans...)
+}
+
+// Reset removes the spans exported
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
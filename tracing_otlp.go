@@ -0,0 +1,306 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultOTLPEndpoint is the traces endpoint of an OpenTelemetry collector running locally
+	defaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+	// defaultOTLPBatchSize is the number of spans queued before they are sent
+	defaultOTLPBatchSize = 512
+	// defaultOTLPFlushInterval is the longest a span is queued before it is sent
+	defaultOTLPFlushInterval = 5 * time.Second
+)
+
+// OTLPExporter sends spans to an OpenTelemetry collector with the OTLP/HTTP protocol
+// in its JSON encoding. Spans are queued and sent in batches. The fields must be set
+// before the exporter is passed to OptionTracing.
+type OTLPExporter struct {
+	Endpoint      string            // the URL of the traces endpoint, defaults to http://localhost:4318/v1/traces
+	Headers       map[string]string // headers added to each export request such as an API key
+	ServiceName   string            // the service.name resource attribute, defaults to $OTEL_SERVICE_NAME
+	Client        *http.Client      // the client used to send the spans, defaults to a client with a 10 second timeout
+	BatchSize     int               // the number of queued spans that are sent right away, defaults to 512
+	FlushInterval time.Duration     // the longest a span is queued, defaults to 5 seconds
+	Logger        *slog.Logger      // the logger for failures sending spans in the background, defaults to slog.Default()
+
+	mu      sync.Mutex
+	pending []Span
+	timer   *time.Timer
+	sending sync.WaitGroup
+}
+
+// NewOTLPExporter creates an exporter sending spans to the OTLP/HTTP traces endpoint.
+// An empty endpoint uses $OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or the local collector.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+
+	return &OTLPExporter{Endpoint: endpoint}
+}
+
+// ExportSpans queues the spans, sending the queue in the background once it reaches the batch size
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending = append(e.pending, spans...)
+
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOTLPBatchSize
+	}
+
+	if len(e.pending) >= batchSize {
+		batch := e.take()
+		e.sending.Add(1)
+		go func() {
+			defer e.sending.Done()
+			if err := e.send(context.Background(), batch); err != nil {
+				loggerOrDefault(e.Logger).Error("Failed to export spans", "spans", len(batch), "error", err)
+			}
+		}()
+	} else if e.timer == nil {
+		interval := e.FlushInterval
+		if interval <= 0 {
+			interval = defaultOTLPFlushInterval
+		}
+		e.timer = time.AfterFunc(interval, func() {
+			if err := e.Flush(context.Background()); err != nil {
+				loggerOrDefault(e.Logger).Error("Failed to export spans", "error", err)
+			}
+		})
+	}
+
+	return nil
+}
+
+// Flush sends the queued spans
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.take()
+	e.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return e.send(ctx, batch)
+}
+
+// Shutdown sends the queued spans and waits for the batches being sent in the background.
+// The exporter can still be used afterwards so the server can be started again.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	err := e.Flush(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		e.sending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// take empties the queue and stops the flush timer, the caller must hold the lock
+func (e *OTLPExporter) take() []Span {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	batch := e.pending
+	e.pending = nil
+
+	return batch
+}
+
+// send posts the spans to the traces endpoint
+func (e *OTLPExporter) send(ctx context.Context, spans []Span) error {
+	body, err := json.Marshal(e.newRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("common/server: otlp export returned %s", res.Status)
+	}
+
+	return nil
+}
+
+// otlpRequest is the OTLP/HTTP JSON request body of an ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpSpanKindServer is the OTLP span kind of a span for a request handled by the server
+const otlpSpanKindServer = 2
+
+// newRequest converts the spans to the OTLP request body
+func (e *OTLPExporter) newRequest(spans []Span) otlpRequest {
+	serviceName := e.ServiceName
+	if serviceName == "" {
+		serviceName = os.Getenv("OTEL_SERVICE_NAME")
+	}
+	if serviceName == "" {
+		serviceName = "unknown_service"
+	}
+
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/go-nm/srv"}}
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: int(span.StatusCode), Message: span.StatusMessage},
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.SpanID.String()
+		}
+		for _, event := range span.Events {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+				Name:         event.Name,
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+
+		scope.Spans = append(scope.Spans, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": serviceName})},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+// otlpAttributes converts the attributes to OTLP key values, values that are not a string,
+// bool or number are sent as their string form
+func otlpAttributes(attributes map[string]interface{}) []otlpAttribute {
+	var kvs []otlpAttribute
+	for key, value := range attributes {
+		var v otlpValue
+		switch value := value.(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			i := strconv.Itoa(value)
+			v.IntValue = &i
+		case int64:
+			i := strconv.FormatInt(value, 10)
+			v.IntValue = &i
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+
+		kvs = append(kvs, otlpAttribute{Key: key, Value: v})
+	}
+
+	return kvs
+}
@@ -0,0 +1,333 @@
+package srv_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestServer_Tracing(t *testing.T) {
+	t.Run("NewTrace", testServer_Tracing_NewTrace)
+	t.Run("Propagation", testServer_Tracing_Propagation)
+	t.Run("InvalidTraceparent", testServer_Tracing_InvalidTraceparent)
+	t.Run("NotSampled", testServer_Tracing_NotSampled)
+	t.Run("ServerError", testServer_Tracing_ServerError)
+	t.Run("Panic", testServer_Tracing_Panic)
+	t.Run("Disabled", testServer_Tracing_Disabled)
+}
+
+func testServer_Tracing_NewTrace(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := srv.NewInMemoryExporter()
+	s := srv.New(srv.OptionTracing(exporter))
+	s.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/users/1")
+
+	// Assert
+	assert.NoError(err)
+	waitFor(func() bool { return len(exporter.Spans()) > 0 })
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		assert.Equal("GET /users/:id", spans[0].Name)
+		assert.Equal("/users/:id", spans[0].Attributes["http.route"])
+		assert.Equal("/users/1", spans[0].Attributes["url.path"])
+		assert.Equal(http.StatusOK, spans[0].Attributes["http.response.status_code"])
+		assert.Equal(srv.SpanStatusUnset, spans[0].StatusCode)
+		assert.True(spans[0].SpanContext.IsValid())
+		assert.False(spans[0].Parent.IsValid())
+		assert.Equal("00-"+spans[0].SpanContext.TraceID.String()+"-"+spans[0].SpanContext.SpanID.String()+"-01", res.Header.Get("traceparent"))
+	}
+}
+
+func testServer_Tracing_Propagation(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := srv.NewInMemoryExporter()
+	s := srv.New(srv.OptionTracing(exporter))
+	outgoing := http.Header{}
+	var sc srv.SpanContext
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		sc = srv.SpanContextFromContext(r.Context())
+		srv.InjectTraceContext(r.Context(), outgoing)
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+	req, _ := http.NewRequest("GET", "http://"+s.Addr().String()+"/test", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	req.Header.Set("tracestate", "vendor=value")
+
+	// Act
+	res, err := http.DefaultClient.Do(req)
+
+	// Assert
+	assert.NoError(err)
+	waitFor(func() bool { return len(exporter.Spans()) > 0 })
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID.String())
+		assert.Equal("00f067aa0ba902b7", spans[0].Parent.SpanID.String())
+		assert.True(spans[0].Parent.Remote)
+		assert.NotEqual(spans[0].Parent.SpanID, spans[0].SpanContext.SpanID)
+		assert.Equal("vendor=value", spans[0].SpanContext.TraceState)
+		assert.Equal(spans[0].SpanContext, sc)
+	}
+	assert.Equal(res.Header.Get("traceparent"), outgoing.Get("traceparent"))
+	assert.True(strings.HasPrefix(outgoing.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	assert.Equal("vendor=value", outgoing.Get("tracestate"))
+	assert.Equal("vendor=value", res.Header.Get("tracestate"))
+}
+
+func testServer_Tracing_InvalidTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+	}{
+		{"ZeroTraceID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{"ZeroSpanID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{"Uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{"VersionFF", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"TooLong", testTraceparent + "-extra"},
+		{"Malformed", "not a traceparent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			assert := assert.New(t)
+			exporter := srv.NewInMemoryExporter()
+			s := srv.New(srv.OptionTracing(exporter))
+			s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+			s.Start("127.0.0.1:0")
+			defer s.Shutdown()
+			req, _ := http.NewRequest("GET", "http://"+s.Addr().String()+"/test", nil)
+			req.Header.Set("traceparent", tt.traceparent)
+
+			// Act
+			_, err := http.DefaultClient.Do(req)
+
+			// Assert
+			assert.NoError(err)
+			waitFor(func() bool { return len(exporter.Spans()) > 0 })
+			spans := exporter.Spans()
+			if assert.Len(spans, 1) {
+				assert.False(spans[0].Parent.IsValid())
+				assert.NotEqual("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID.String())
+			}
+		})
+	}
+}
+
+func testServer_Tracing_NotSampled(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := srv.NewInMemoryExporter()
+	s := srv.New(srv.OptionTracing(exporter))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	req, _ := http.NewRequest("GET", "http://"+s.Addr().String()+"/test", nil)
+	req.Header.Set("traceparent", strings.TrimSuffix(testTraceparent, "01")+"00")
+
+	// Act
+	res, err := http.DefaultClient.Do(req)
+	s.Shutdown()
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(exporter.Spans())
+	assert.True(strings.HasSuffix(res.Header.Get("traceparent"), "-00"))
+}
+
+func testServer_Tracing_ServerError(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := srv.NewInMemoryExporter()
+	s := srv.New(srv.OptionTracing(exporter))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	_, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	waitFor(func() bool { return len(exporter.Spans()) > 0 })
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		assert.Equal(srv.SpanStatusError, spans[0].StatusCode)
+		assert.Equal(http.StatusBadGateway, spans[0].Attributes["http.response.status_code"])
+	}
+}
+
+func testServer_Tracing_Panic(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	exporter := srv.NewInMemoryExporter()
+	s := srv.New(srv.OptionTracing(exporter))
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		panic("something broke")
+	})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	waitFor(func() bool { return len(exporter.Spans()) > 0 })
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		assert.Equal(srv.SpanStatusError, spans[0].StatusCode)
+		assert.Equal("something broke", spans[0].StatusMessage)
+		if assert.Len(spans[0].Events, 1) {
+			assert.Equal("exception", spans[0].Events[0].Name)
+			assert.Equal("something broke", spans[0].Events[0].Attributes["exception.message"])
+			assert.NotEmpty(spans[0].Events[0].Attributes["exception.stacktrace"])
+		}
+	}
+}
+
+func testServer_Tracing_Disabled(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	s := srv.New()
+	s.GET("/test", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	s.Start("127.0.0.1:0")
+	defer s.Shutdown()
+
+	// Act
+	res, err := http.Get("http://" + s.Addr().String() + "/test")
+
+	// Assert
+	assert.NoError(err)
+	assert.Empty(res.Header.Get("traceparent"))
+}
+
+func TestOTLPExporter(t *testing.T) {
+	t.Run("Batch", testOTLPExporter_Batch)
+	t.Run("Shutdown", testOTLPExporter_Shutdown)
+	t.Run("Error", testOTLPExporter_Error)
+}
+
+// otlpCollector is a test collector recording the OTLP/HTTP JSON requests it receives
+func otlpCollector(requests chan<- map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		body["contentType"] = r.Header.Get("Content-Type")
+		body["apiKey"] = r.Header.Get("X-Api-Key")
+		requests <- body
+	}))
+}
+
+// testSpan returns a finished span for exporting
+func testSpan(name string) srv.Span {
+	return srv.Span{
+		Name:        name,
+		SpanContext: srv.SpanContext{TraceID: srv.TraceID{1}, SpanID: srv.SpanID{2}, Sampled: true},
+		Parent:      srv.SpanContext{TraceID: srv.TraceID{1}, SpanID: srv.SpanID{3}},
+		StartTime:   time.Unix(1, 0),
+		EndTime:     time.Unix(2, 0),
+		Attributes:  map[string]interface{}{"http.route": "/test", "http.response.status_code": 200},
+		StatusCode:  srv.SpanStatusError,
+	}
+}
+
+func testOTLPExporter_Batch(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	requests := make(chan map[string]interface{}, 1)
+	collector := otlpCollector(requests)
+	defer collector.Close()
+	exporter := srv.NewOTLPExporter(collector.URL + "/v1/traces")
+	exporter.BatchSize = 2
+	exporter.ServiceName = "test-service"
+	exporter.Headers = map[string]string{"X-Api-Key": "secret"}
+
+	// Act
+	exporter.ExportSpans(context.Background(), []srv.Span{testSpan("first")})
+	exporter.ExportSpans(context.Background(), []srv.Span{testSpan("second")})
+	var body map[string]interface{}
+	select {
+	case body = <-requests:
+	case <-time.After(time.Second):
+	}
+
+	// Assert
+	if assert.NotNil(body) {
+		assert.Equal("application/json", body["contentType"])
+		assert.Equal("secret", body["apiKey"])
+		resource := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		assert.Contains(resource["resource"].(map[string]interface{})["attributes"], map[string]interface{}{
+			"key": "service.name", "value": map[string]interface{}{"stringValue": "test-service"},
+		})
+		spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+		if assert.Len(spans, 2) {
+			span := spans[0].(map[string]interface{})
+			assert.Equal("first", span["name"])
+			assert.Equal("01000000000000000000000000000000", span["traceId"])
+			assert.Equal("0200000000000000", span["spanId"])
+			assert.Equal("0300000000000000", span["parentSpanId"])
+			assert.Equal(float64(2), span["kind"])
+			assert.Equal("1000000000", span["startTimeUnixNano"])
+			assert.Equal(map[string]interface{}{"code": float64(2)}, span["status"])
+			assert.Contains(span["attributes"], map[string]interface{}{
+				"key": "http.response.status_code", "value": map[string]interface{}{"intValue": "200"},
+			})
+		}
+	}
+}
+
+func testOTLPExporter_Shutdown(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	requests := make(chan map[string]interface{}, 1)
+	collector := otlpCollector(requests)
+	defer collector.Close()
+	exporter := srv.NewOTLPExporter(collector.URL)
+
+	// Act
+	exporter.ExportSpans(context.Background(), []srv.Span{testSpan("queued")})
+	err := exporter.Shutdown(context.Background())
+
+	// Assert
+	assert.NoError(err)
+	assert.Len(requests, 1)
+}
+
+func testOTLPExporter_Error(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+	exporter := srv.NewOTLPExporter(collector.URL)
+
+	// Act
+	exporter.ExportSpans(context.Background(), []srv.Span{testSpan("failed")})
+	err := exporter.Flush(context.Background())
+
+	// Assert
+	assert.Error(err)
+}
@@ -0,0 +1,151 @@
+package srv
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envUpgradeFDs lists the names of the listeners inherited from the parent process
+	// in the order of their file descriptors starting at upgradeFDsStart
+	envUpgradeFDs = "SRV_UPGRADE_FDS"
+
+	// envUpgradeReadyFD is the file descriptor the child process writes to once it is serving
+	envUpgradeReadyFD = "SRV_UPGRADE_READY_FD"
+
+	// upgradeFDsStart is the first file descriptor passed to the child process
+	upgradeFDsStart = 3
+
+	// httpListenerName is the name of the main listener passed during an upgrade
+	httpListenerName = "http"
+)
+
+// ErrUpgradeNotSupported is the error returned when the listener cannot be passed to a new process
+var ErrUpgradeNotSupported = errors.New("common/server: listener does not support upgrade")
+
+// inherited holds the listener file descriptors passed by the parent process during an upgrade
+var inherited struct {
+	once sync.Once
+	mu   sync.Mutex
+	fds  map[string]int
+}
+
+// Upgrade replaces the running binary without dropping connections. The current executable
+// is started again with the same arguments and the listening sockets, including the admin
+// listener, passed as inherited file descriptors. Upgrade waits up to the shutdown timeout for the new process to report
+// that it is serving, after which the caller should call Shutdown to drain this process.
+// When OptionUpgradeSignal is set Run does this automatically on the signal.
+func (s *Server) Upgrade() error {
+	s.mu.Lock()
+	names := []string{httpListenerName}
+	listeners := []net.Listener{s.listener}
+	if s.admin.listener != nil && s.admin.provided == nil {
+		names = append(names, adminListenerName)
+		listeners = append(listeners, s.admin.listener)
+	}
+	s.mu.Unlock()
+
+	if listeners[0] == nil {
+		return ErrServerStopped
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return errors.New("common/server: failed to upgrade: " + err.Error())
+	}
+	defer readyR.Close()
+
+	proc, err := startUpgradeProcess(names, listeners, readyW)
+	readyW.Close()
+	if err == ErrUpgradeNotSupported {
+		return err
+	} else if err != nil {
+		return errors.New("common/server: failed to upgrade: " + err.Error())
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		_, err := proc.Wait()
+		exited <- err
+	}()
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyR.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	timer := time.NewTimer(s.shutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			proc.Kill()
+			return errors.New("common/server: failed to upgrade: new process did not report ready")
+		}
+
+	case <-exited:
+		return errors.New("common/server: failed to upgrade: new process exited")
+
+	case <-timer.C:
+		proc.Kill()
+		return errors.New("common/server: failed to upgrade: timed out waiting for new process")
+	}
+
+	// The socket files now belong to the new process
+	for _, l := range listeners {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+
+	return nil
+}
+
+// inheritedListener returns the listener with the name provided that was passed by
+// the parent process during an upgrade. The listener is nil if none was inherited.
+func inheritedListener(name string) (net.Listener, error) {
+	inherited.once.Do(func() {
+		inherited.fds = map[string]int{}
+		if names := os.Getenv(envUpgradeFDs); names != "" {
+			for i, n := range strings.Split(names, ",") {
+				inherited.fds[n] = upgradeFDsStart + i
+			}
+		}
+		os.Unsetenv(envUpgradeFDs)
+	})
+
+	inherited.mu.Lock()
+	fd, ok := inherited.fds[name]
+	delete(inherited.fds, name)
+	inherited.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	f := os.NewFile(uintptr(fd), "UPGRADE_FD_"+name)
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+// notifyUpgradeReady tells the parent process that this process is serving requests
+// so the parent can begin its graceful shutdown
+func notifyUpgradeReady() {
+	fd, err := strconv.Atoi(os.Getenv(envUpgradeReadyFD))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(envUpgradeReadyFD)
+
+	f := os.NewFile(uintptr(fd), "UPGRADE_READY_FD")
+	f.Write([]byte{1})
+	f.Close()
+}
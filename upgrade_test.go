@@ -0,0 +1,71 @@
+package srv_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+// envUpgradeChild is set when the test binary is started as the new process of an upgrade
+const envUpgradeChild = "SRV_TEST_UPGRADE_CHILD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envUpgradeChild) == "1" {
+		runUpgradeChild()
+		return
+	}
+
+	os.Exit(m.Run())
+}
+
+// runUpgradeChild serves on the inherited listener for a short time and exits
+func runUpgradeChild() {
+	s := srv.New()
+	s.GET("/who", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Write([]byte("child"))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.RunContext(ctx, "127.0.0.1:0")
+}
+
+func TestServer_Upgrade(t *testing.T) {
+	t.Run("NotRunning", testServer_Upgrade_NotRunning)
+	t.Run("NotSupported", testServer_Upgrade_NotSupported)
+}
+
+func testServer_Upgrade_NotRunning(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+
+	// Act
+	err := srv.New().Upgrade()
+
+	// Assert
+	assert.Equal(srv.ErrServerStopped, err)
+}
+
+func testServer_Upgrade_NotSupported(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := srv.New()
+	go s.Serve(struct{ net.Listener }{l})
+	defer s.Shutdown()
+	waitFor(s.IsRunning)
+
+	// Act
+	err := s.Upgrade()
+
+	// Assert
+	assert.Equal(srv.ErrUpgradeNotSupported, err)
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+// +build !windows
+
+package srv
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// startUpgradeProcess starts the current executable with the same arguments passing the
+// listeners and the ready pipe as inherited file descriptors. The descriptors are duplicated
+// directly because converting a listener to an *os.File would put the shared socket into
+// blocking mode and stall this process's accept loop.
+func startUpgradeProcess(names []string, listeners []net.Listener, ready *os.File) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+	for _, l := range listeners {
+		fd, err := dupListenerFD(l)
+		if err != nil {
+			return nil, err
+		}
+		defer syscall.Close(int(fd))
+		files = append(files, fd)
+	}
+	files = append(files, ready.Fd())
+
+	env := append(os.Environ(),
+		envUpgradeFDs+"="+strings.Join(names, ","),
+		envUpgradeReadyFD+"="+strconv.Itoa(upgradeFDsStart+len(listeners)),
+	)
+
+	pid, err := syscall.ForkExec(exe, os.Args, &syscall.ProcAttr{Env: env, Files: files})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.FindProcess(pid)
+}
+
+// dupListenerFD duplicates the file descriptor of the listener without changing its mode
+func dupListenerFD(l net.Listener) (uintptr, error) {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return 0, ErrUpgradeNotSupported
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var fd int
+	var dupErr error
+	err = rc.Control(func(orig uintptr) {
+		syscall.ForkLock.RLock()
+		defer syscall.ForkLock.RUnlock()
+
+		if fd, dupErr = syscall.Dup(int(orig)); dupErr == nil {
+			syscall.CloseOnExec(fd)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uintptr(fd), dupErr
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package srv_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-nm/srv"
+)
+
+func TestServer_UpgradeProcess(t *testing.T) {
+	t.Run("Success", testServer_UpgradeProcess_Success)
+}
+
+func testServer_UpgradeProcess_Success(t *testing.T) {
+	// Arrange
+	assert := assert.New(t)
+	os.Setenv(envUpgradeChild, "1")
+	defer os.Unsetenv(envUpgradeChild)
+	s := srv.New()
+	s.GET("/who", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Write([]byte("parent"))
+	})
+	s.Start("127.0.0.1:0")
+	addr := s.Addr().String()
+
+	// Act
+	err := s.Upgrade()
+	s.Shutdown()
+	res, getErr := http.Get("http://" + addr + "/who")
+
+	// Assert
+	assert.NoError(err)
+	assert.NoError(getErr)
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Equal("child", string(body))
+}
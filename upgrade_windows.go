@@ -0,0 +1,11 @@
+package srv
+
+import (
+	"net"
+	"os"
+)
+
+// startUpgradeProcess is not supported on windows as sockets cannot be inherited by file descriptor
+func startUpgradeProcess(names []string, listeners []net.Listener, ready *os.File) (*os.Process, error) {
+	return nil, ErrUpgradeNotSupported
+}